@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vvatanabe/gitb/internal/backlog"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// apiKeyEnv overrides the stored credentials, e.g. in CI.
+const apiKeyEnv = "BACKLOG_API_KEY"
+
+// Credential is the credential of Backlog API for a space.
+type Credential struct {
+	APIKey       string    `json:"apiKey,omitempty"`
+	AccessToken  string    `json:"accessToken,omitempty"`
+	RefreshToken string    `json:"refreshToken,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"`
+	UserID       string    `json:"userId,omitempty"`
+	UserName     string    `json:"userName,omitempty"`
+}
+
+func (c *Credential) method() string {
+	if c.AccessToken != "" {
+		return "OAuth 2.0"
+	}
+	return "API key"
+}
+
+func (c *Credential) expired(now time.Time) bool {
+	return c.AccessToken != "" && !c.Expiry.IsZero() && now.After(c.Expiry.Add(-time.Minute))
+}
+
+// Credentials maps the base URL of a space to its Credential.
+type Credentials map[string]*Credential
+
+func credentialsFile() string {
+	file := userConfigFile()
+	if file == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(file), "credentials")
+}
+
+func LoadCredentials(file string) (Credentials, error) {
+	creds := make(Credentials)
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return creds, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &creds); err != nil {
+		return nil, errors.Wrapf(err, "could not parse %s", file)
+	}
+	return creds, nil
+}
+
+// Save writes c to file, readable only by the owner.
+func (c Credentials) Save(file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// APIClient returns a client of Backlog API authenticated for the space of
+// the repository, with $BACKLOG_API_KEY or the stored credential.
+func (b *BacklogRepository) APIClient() (*backlog.Client, error) {
+	if b.api != nil {
+		return b.api, nil
+	}
+	if key := os.Getenv(apiKeyEnv); key != "" {
+		b.api = b.NewAPIClient(key)
+		return b.api, nil
+	}
+	baseURL := b.urlBuilder().BaseURL()
+	file := credentialsFile()
+	creds, err := LoadCredentials(file)
+	if err != nil {
+		return nil, err
+	}
+	cred, ok := creds[baseURL]
+	if !ok {
+		return nil, errors.Errorf("not logged in to %s. run `gitb auth login` or set %s", baseURL, apiKeyEnv)
+	}
+	if cred.AccessToken == "" {
+		b.api = b.NewAPIClient(cred.APIKey)
+		return b.api, nil
+	}
+	c := backlog.NewClient(baseURL)
+	if cred.expired(time.Now()) {
+		if err := refreshCredential(context.Background(), c, oauthConfig(b.config), cred); err != nil {
+			return nil, errors.Wrap(err, "could not refresh the access token. run `gitb auth login --oauth`")
+		}
+		if err := creds.Save(file); err != nil {
+			return nil, err
+		}
+	}
+	b.api = c.SetAccessToken(cred.AccessToken)
+	return b.api, nil
+}
+
+func refreshCredential(ctx context.Context, c *backlog.Client, conf *backlog.OAuthConfig, cred *Credential) error {
+	if cred.RefreshToken == "" {
+		return errors.New("no refresh token")
+	}
+	t, err := c.RefreshToken(ctx, conf, cred.RefreshToken)
+	if err != nil {
+		return err
+	}
+	cred.AccessToken = t.AccessToken
+	cred.RefreshToken = t.RefreshToken
+	cred.Expiry = t.Expiry(time.Now())
+	return nil
+}
+
+func oauthConfig(cfg *Config) *backlog.OAuthConfig {
+	return &backlog.OAuthConfig{
+		ClientID:     cfg.Get("oauth.clientid"),
+		ClientSecret: cfg.Get("oauth.clientsecret"),
+		RedirectURI:  cfg.Get("oauth.redirecturi"),
+	}
+}
+
+// Login validates cred against the API, and stores it for the space of c.
+func Login(ctx context.Context, c *backlog.Client, cred *Credential, file string) (*backlog.User, error) {
+	if cred.AccessToken != "" {
+		c.SetAccessToken(cred.AccessToken)
+	} else {
+		c.SetAPIKey(cred.APIKey)
+	}
+	me, err := c.GetMyself(ctx)
+	if backlog.IsUnauthorized(err) {
+		return nil, errors.New("the credential was rejected by " + c.BaseURL())
+	}
+	if err != nil {
+		return nil, err
+	}
+	cred.UserID = me.UserID
+	cred.UserName = me.Name
+	creds, err := LoadCredentials(file)
+	if err != nil {
+		return nil, err
+	}
+	creds[c.BaseURL()] = cred
+	return me, creds.Save(file)
+}
+
+// Logout removes the credential of baseURL, or all credentials when baseURL is empty.
+func Logout(file, baseURL string) error {
+	creds, err := LoadCredentials(file)
+	if err != nil {
+		return err
+	}
+	if baseURL == "" {
+		creds = make(Credentials)
+	} else if _, ok := creds[baseURL]; !ok {
+		return errors.Errorf("not logged in to %s", baseURL)
+	} else {
+		delete(creds, baseURL)
+	}
+	return creds.Save(file)
+}
+
+// AuthStatus prints the spaces logged in and as whom, validating each credential.
+func AuthStatus(ctx context.Context, w io.Writer, file string, newClient func(baseURL string) *backlog.Client) error {
+	if os.Getenv(apiKeyEnv) != "" {
+		fmt.Fprintf(w, "%s is set, and it is used instead of the credentials below.\n", apiKeyEnv)
+	}
+	creds, err := LoadCredentials(file)
+	if err != nil {
+		return err
+	}
+	if len(creds) == 0 {
+		fmt.Fprintln(w, "Not logged in to any spaces. run `gitb auth login`")
+		return nil
+	}
+	var baseURLs []string
+	for u := range creds {
+		baseURLs = append(baseURLs, u)
+	}
+	sort.Strings(baseURLs)
+	for _, u := range baseURLs {
+		cred := creds[u]
+		c := newClient(u)
+		if cred.AccessToken != "" {
+			c.SetAccessToken(cred.AccessToken)
+		} else {
+			c.SetAPIKey(cred.APIKey)
+		}
+		fmt.Fprintln(w, u)
+		me, err := c.GetMyself(ctx)
+		switch {
+		case err == nil:
+			fmt.Fprintf(w, "  Logged in as %s (%s) with %s\n", me.Name, me.UserID, cred.method())
+		case backlog.IsUnauthorized(err) && cred.expired(time.Now()):
+			fmt.Fprintf(w, "  The access token of %s (%s) has expired\n", cred.UserName, cred.UserID)
+		default:
+			fmt.Fprintf(w, "  The credential of %s (%s) is invalid: %v\n", cred.UserName, cred.UserID, err)
+		}
+	}
+	return nil
+}
+
+// ReadAPIKey prompts for an API key. The input is not echoed on terminals.
+func ReadAPIKey(in *os.File, out io.Writer, baseURL string) (string, error) {
+	fmt.Fprintf(out, "Issue an API key at %s/EditApiSettings.action\n", baseURL)
+	fmt.Fprint(out, "API key: ")
+	var line string
+	if isTerminal(in) {
+		b, err := terminal.ReadPassword(int(in.Fd()))
+		fmt.Fprintln(out)
+		if err != nil {
+			return "", err
+		}
+		line = string(b)
+	} else {
+		var err error
+		line, err = bufio.NewReader(in).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+	}
+	key := strings.TrimSpace(line)
+	if key == "" {
+		return "", errors.New("API key is empty")
+	}
+	return key, nil
+}
+
+func isTerminal(f *os.File) bool {
+	return terminal.IsTerminal(int(f.Fd()))
+}
+
+// oauthTimeout is how long AuthorizeOAuth waits for the user to authorize the
+// application in the browser.
+var oauthTimeout = 5 * time.Minute
+
+// AuthorizeOAuth runs the OAuth 2.0 authorization code flow. It opens the
+// authorization page, and receives the code on the loopback redirect URI.
+func AuthorizeOAuth(ctx context.Context, c *backlog.Client, conf *backlog.OAuthConfig, open URLSink) (*backlog.Token, error) {
+	if conf.ClientID == "" || conf.ClientSecret == "" {
+		return nil, errors.New("set oauth.clientid and oauth.clientsecret of gitb config to the application registered on Backlog Developer")
+	}
+	redirect, err := url.Parse(conf.RedirectURI)
+	if err != nil {
+		return nil, err
+	}
+	l, err := net.Listen("tcp", redirect.Host)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not listen on the redirect URI %s", conf.RedirectURI)
+	}
+	state, err := randomState()
+	if err != nil {
+		return nil, err
+	}
+	codes := make(chan string, 1)
+	errs := make(chan error, 1)
+	server := &http.Server{Handler: oauthCallbackHandler(redirect.Path, state, codes, errs)}
+	go func() {
+		_ = server.Serve(l)
+	}()
+	defer server.Close()
+
+	if err := open(c.AuthorizationURL(conf, state)); err != nil {
+		return nil, err
+	}
+	timer := time.NewTimer(oauthTimeout)
+	defer timer.Stop()
+	select {
+	case code := <-codes:
+		return c.ExchangeCode(ctx, conf, code)
+	case err := <-errs:
+		return nil, err
+	case <-timer.C:
+		return nil, errors.Errorf("timed out waiting for the authorization after %v", oauthTimeout)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func oauthCallbackHandler(path, state string, codes chan<- string, errs chan<- error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+		q := r.URL.Query()
+		if q.Get("state") != state {
+			http.Error(w, "state mismatch", http.StatusBadRequest)
+			return
+		}
+		if e := q.Get("error"); e != "" {
+			http.Error(w, "authorization failed: "+e, http.StatusBadRequest)
+			// Only the first result is waited for. The later ones, e.g. of a
+			// reloaded page, must not block the handler.
+			select {
+			case errs <- errors.New("authorization failed: " + e):
+			default:
+			}
+			return
+		}
+		code := q.Get("code")
+		if code == "" {
+			http.Error(w, "code is missing", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintln(w, "Authorized. You can close this page and return to the terminal.")
+		select {
+		case codes <- code:
+		default:
+		}
+	})
+}
+
+func randomState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vvatanabe/gitb/internal/backlog"
+)
+
+// setenv sets the environment variable during the test.
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+	prev, ok := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if ok {
+			_ = os.Setenv(key, prev)
+		} else {
+			_ = os.Unsetenv(key)
+		}
+	})
+}
+
+func newMyselfServer(t *testing.T, apiKey string) *httptest.Server {
+	t.Helper()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/users/myself" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("apiKey") != apiKey && r.Header.Get("Authorization") != "Bearer "+apiKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"errors":[{"message":"Authentication failure.","code":11}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":1,"userId":"admin","name":"Admin"}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestCredentials_Save(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "gitb", "credentials")
+	want := Credentials{"https://foo.backlog.com": {APIKey: "key", UserID: "admin"}}
+	if err := want.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("Credentials.Save() mode = %v, want %v", fi.Mode().Perm(), os.FileMode(0600))
+	}
+	got, err := LoadCredentials(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := got["https://foo.backlog.com"]; c == nil || c.APIKey != "key" || c.UserID != "admin" {
+		t.Errorf("LoadCredentials() = %v, want %v", got, want)
+	}
+}
+
+func TestLoadCredentials_notExist(t *testing.T) {
+	got, err := LoadCredentials(filepath.Join(t.TempDir(), "credentials"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("LoadCredentials() = %v, want empty", got)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	s := newMyselfServer(t, "valid")
+	tests := []struct {
+		name    string
+		cred    *Credential
+		wantErr bool
+	}{
+		{
+			name: "api key",
+			cred: &Credential{APIKey: "valid"},
+		},
+		{
+			name: "access token",
+			cred: &Credential{AccessToken: "valid"},
+		},
+		{
+			name:    "rejected",
+			cred:    &Credential{APIKey: "invalid"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "credentials")
+			me, err := Login(context.Background(), backlog.NewClient(s.URL), tt.cred, file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Login() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			creds, _ := LoadCredentials(file)
+			if tt.wantErr {
+				if len(creds) != 0 {
+					t.Errorf("Login() stored %v for a rejected credential", creds)
+				}
+				return
+			}
+			if me.UserID != "admin" {
+				t.Errorf("Login() = %v, want admin", me.UserID)
+			}
+			if c := creds[s.URL]; c == nil || c.UserID != "admin" {
+				t.Errorf("Login() stored %v, want the credential of admin", creds)
+			}
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "credentials")
+	creds := Credentials{
+		"https://foo.backlog.com": {APIKey: "foo"},
+		"https://bar.backlog.com": {APIKey: "bar"},
+	}
+	if err := creds.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := Logout(file, "https://foo.backlog.com"); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := LoadCredentials(file)
+	if _, ok := got["https://foo.backlog.com"]; ok || len(got) != 1 {
+		t.Errorf("Logout() left %v", got)
+	}
+	if err := Logout(file, "https://foo.backlog.com"); err == nil {
+		t.Error("Logout() error = nil, want not logged in")
+	}
+	if err := Logout(file, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := LoadCredentials(file); len(got) != 0 {
+		t.Errorf("Logout() left %v", got)
+	}
+}
+
+func TestAuthStatus(t *testing.T) {
+	setenv(t, apiKeyEnv, "")
+	s := newMyselfServer(t, "valid")
+	file := filepath.Join(t.TempDir(), "credentials")
+	creds := Credentials{
+		s.URL:                     {APIKey: "valid"},
+		"https://foo.backlog.com": {APIKey: "invalid", UserID: "foo"},
+	}
+	if err := creds.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	err := AuthStatus(context.Background(), &buf, file, func(string) *backlog.Client {
+		return backlog.NewClient(s.URL)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"Logged in as Admin (admin) with API key",
+		"The credential of  (foo) is invalid",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("AuthStatus() = %q, want to contain %q", got, want)
+		}
+	}
+}
+
+func TestBacklogRepository_APIClient(t *testing.T) {
+	dir := t.TempDir()
+	setenv(t, "XDG_CONFIG_HOME", dir)
+	creds := Credentials{"https://foo.backlog.com": {APIKey: "stored"}}
+	if err := creds.Save(filepath.Join(dir, "gitb", "credentials")); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		env      string
+		spaceKey string
+		wantErr  bool
+	}{
+		{
+			name:     "stored",
+			spaceKey: "foo",
+		},
+		{
+			name:     "environment",
+			env:      "env",
+			spaceKey: "bar",
+		},
+		{
+			name:     "not logged in",
+			spaceKey: "bar",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setenv(t, apiKeyEnv, tt.env)
+			b := &BacklogRepository{domain: "backlog.com", spaceKey: tt.spaceKey}
+			got, err := b.APIClient()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BacklogRepository.APIClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.BaseURL() != "https://"+tt.spaceKey+".backlog.com" {
+				t.Errorf("BacklogRepository.APIClient() BaseURL = %v", got.BaseURL())
+			}
+		})
+	}
+}
+
+func Test_oauthCallbackHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		wantCode string
+		wantErr  bool
+	}{
+		{
+			name:     "authorized",
+			query:    "?state=xyz&code=abc",
+			wantCode: "abc",
+		},
+		{
+			name:  "state mismatch",
+			query: "?state=other&code=abc",
+		},
+		{
+			name:    "denied",
+			query:   "?state=xyz&error=access_denied",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codes := make(chan string, 1)
+			errs := make(chan error, 1)
+			h := oauthCallbackHandler("/callback", "xyz", codes, errs)
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/callback"+tt.query, nil))
+			var gotCode string
+			var gotErr error
+			select {
+			case gotCode = <-codes:
+			case gotErr = <-errs:
+			default:
+			}
+			if gotCode != tt.wantCode {
+				t.Errorf("oauthCallbackHandler() code = %v, want %v", gotCode, tt.wantCode)
+			}
+			if (gotErr != nil) != tt.wantErr {
+				t.Errorf("oauthCallbackHandler() error = %v, wantErr %v", gotErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_oauthCallbackHandler_again(t *testing.T) {
+	codes := make(chan string, 1)
+	errs := make(chan error, 1)
+	h := oauthCallbackHandler("/callback", "xyz", codes, errs)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// The results after the first one are dropped.
+		for _, q := range []string{"?state=xyz&code=abc", "?state=xyz&code=def", "?state=xyz&error=access_denied", "?state=xyz&error=access_denied"} {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/callback"+q, nil))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("oauthCallbackHandler() blocked on the second callback")
+	}
+	if got := <-codes; got != "abc" {
+		t.Errorf("oauthCallbackHandler() code = %v, want abc", got)
+	}
+}
+
+func TestAuthorizeOAuth_timeout(t *testing.T) {
+	defer func(d time.Duration) { oauthTimeout = d }(oauthTimeout)
+	oauthTimeout = 10 * time.Millisecond
+	conf := &backlog.OAuthConfig{
+		ClientID:     "id",
+		ClientSecret: "secret",
+		RedirectURI:  "http://127.0.0.1:0/callback",
+	}
+	opened := false
+	open := func(u string) error {
+		opened = true
+		return nil
+	}
+	_, err := AuthorizeOAuth(context.Background(), backlog.NewClient("https://foo.backlog.com"), conf, open)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("AuthorizeOAuth() error = %v, want timeout", err)
+	}
+	if !opened {
+		t.Error("AuthorizeOAuth() did not open the authorization URL")
+	}
+}
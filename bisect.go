@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vvatanabe/gitb/internal/backlog"
+)
+
+// bisectArgs splits the arguments of bisect-pr, <good> <bad> -- <command>.
+func bisectArgs(args []string) (good, bad string, command []string, err error) {
+	var revs []string
+	for i, v := range args {
+		if v == "--" {
+			command = args[i+1:]
+			break
+		}
+		revs = append(revs, v)
+	}
+	if len(revs) != 2 {
+		return "", "", nil, errors.New("specify <good> and <bad>")
+	}
+	if len(command) == 0 {
+		return "", "", nil, errors.New("specify the test command after --")
+	}
+	return revs[0], revs[1], command, nil
+}
+
+// BisectCulprit is the first bad commit found by bisect-pr.
+type BisectCulprit struct {
+	Commit      string
+	Subject     string
+	PullRequest int
+	// Summary is the summary of the pull request, given by Backlog API.
+	Summary string
+	URL     string
+}
+
+// BisectPR runs git bisect on the first-parent history between good and bad,
+// which is made of the merges of pull requests, testing each of them with
+// command. Then it prints the pull request of the first bad one.
+func (b *BacklogRepository) BisectPR(w io.Writer, good, bad string, command []string) error {
+	if b.bisecting() {
+		return errors.New("a git bisect is in progress. finish it with `git bisect reset` first")
+	}
+	badHash, err := b.verifyCommit(bad)
+	if err != nil {
+		return err
+	}
+	if _, err := b.verifyCommit(good); err != nil {
+		return err
+	}
+	resolver, err := b.newPRResolver()
+	if err != nil {
+		return err
+	}
+	if err := b.runBisect("start", "--first-parent", badHash, good); err != nil {
+		return errors.Wrap(err, "could not start git bisect")
+	}
+	defer func() {
+		_ = b.runBisect("reset")
+	}()
+	if err := b.runBisect(append([]string{"run"}, command...)...); err != nil {
+		return errors.Wrap(err, "git bisect run failed")
+	}
+	out, err := b.runGit("show", "-s", "--format=%H%x00%s", "refs/bisect/bad")
+	if err != nil {
+		return errors.Wrap(err, "could not find the first bad commit")
+	}
+	fields := strings.SplitN(strings.TrimSpace(out), "\x00", 2)
+	if len(fields) < 2 {
+		return errors.New("could not find the first bad commit")
+	}
+	culprit := &BisectCulprit{Commit: fields[0], Subject: fields[1]}
+
+	resolver.setHead(badHash)
+	if culprit.PullRequest, err = resolver.resolve(culprit.Commit); err != nil {
+		return err
+	}
+	if culprit.PullRequest > 0 {
+		culprit.URL = b.urlBuilder().PullRequestURL(strconv.Itoa(culprit.PullRequest))
+		if client, err := b.APIClient(); err == nil {
+			pr, err := client.GetPullRequest(context.Background(), b.projectKey, b.repoName, culprit.PullRequest)
+			if err != nil && !backlog.IsNotFound(err) {
+				return err
+			}
+			if pr != nil {
+				culprit.Summary = pr.Summary
+			}
+		}
+	} else {
+		culprit.URL = b.urlBuilder().CommitURL(culprit.Commit)
+	}
+	printBisectCulprit(w, culprit)
+	return nil
+}
+
+// bisecting reports whether a git bisect is in progress, which bisect-pr
+// would otherwise reset.
+func (b *BacklogRepository) bisecting() bool {
+	if _, err := b.runGit("rev-parse", "-q", "--verify", "refs/bisect/bad"); err == nil {
+		return true
+	}
+	file, err := b.gitPath("rev-parse", "--git-path", "BISECT_LOG")
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(file)
+	return err == nil
+}
+
+// runBisect runs git bisect, showing its progress on stderr.
+func (b *BacklogRepository) runBisect(args ...string) error {
+	cmd := b.repo.Git(append([]string{"bisect"}, args...)...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func printBisectCulprit(w io.Writer, c *BisectCulprit) {
+	if c.PullRequest == 0 {
+		fmt.Fprintf(w, "The first bad commit is %s, which is not of a pull request\n", abbrev(c.Commit, 8))
+		fmt.Fprintf(w, "  %s\n", c.Subject)
+		fmt.Fprintf(w, "  %s\n", c.URL)
+		return
+	}
+	summary := c.Summary
+	if summary == "" {
+		summary = c.Subject
+	}
+	fmt.Fprintf(w, "The first bad pull request is PR #%d\n", c.PullRequest)
+	fmt.Fprintf(w, "  %s\n", summary)
+	fmt.Fprintf(w, "  %s\n", c.URL)
+	fmt.Fprintf(w, "  merged at %s\n", abbrev(c.Commit, 8))
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func Test_bisectArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		wantGood    string
+		wantBad     string
+		wantCommand []string
+		wantErr     bool
+	}{
+		{name: "ok", args: []string{"v1.0", "master", "--", "make", "test"}, wantGood: "v1.0", wantBad: "master", wantCommand: []string{"make", "test"}},
+		{name: "command with --", args: []string{"v1.0", "master", "--", "go", "test", "--", "-v"}, wantGood: "v1.0", wantBad: "master", wantCommand: []string{"go", "test", "--", "-v"}},
+		{name: "no command", args: []string{"v1.0", "master"}, wantErr: true},
+		{name: "empty command", args: []string{"v1.0", "master", "--"}, wantErr: true},
+		{name: "no bad", args: []string{"v1.0", "--", "make"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			good, bad, command, err := bisectArgs(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("bisectArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if good != tt.wantGood || bad != tt.wantBad || !reflect.DeepEqual(command, tt.wantCommand) {
+				t.Errorf("bisectArgs() = %v, %v, %v, want %v, %v, %v", good, bad, command, tt.wantGood, tt.wantBad, tt.wantCommand)
+			}
+		})
+	}
+}
+
+func Test_printBisectCulprit(t *testing.T) {
+	tests := []struct {
+		name    string
+		culprit *BisectCulprit
+		want    string
+	}{
+		{
+			name: "pull request",
+			culprit: &BisectCulprit{
+				Commit:      "1234567890abcdef",
+				Subject:     "Merge pull request #12 feature/a into master",
+				PullRequest: 12,
+				Summary:     "Add the login page",
+				URL:         "https://foo.backlog.com/git/BAR/baz/pullRequests/12",
+			},
+			want: `The first bad pull request is PR #12
+  Add the login page
+  https://foo.backlog.com/git/BAR/baz/pullRequests/12
+  merged at 12345678
+`,
+		},
+		{
+			name: "without api",
+			culprit: &BisectCulprit{
+				Commit:      "1234567890abcdef",
+				Subject:     "Add the login page (#12)",
+				PullRequest: 12,
+				URL:         "https://foo.backlog.com/git/BAR/baz/pullRequests/12",
+			},
+			want: `The first bad pull request is PR #12
+  Add the login page (#12)
+  https://foo.backlog.com/git/BAR/baz/pullRequests/12
+  merged at 12345678
+`,
+		},
+		{
+			name: "direct commit",
+			culprit: &BisectCulprit{
+				Commit:  "1234567890abcdef",
+				Subject: "Fix a typo",
+				URL:     "https://foo.backlog.com/git/BAR/baz/commit/1234567890abcdef",
+			},
+			want: `The first bad commit is 12345678, which is not of a pull request
+  Fix a typo
+  https://foo.backlog.com/git/BAR/baz/commit/1234567890abcdef
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			printBisectCulprit(&buf, tt.culprit)
+			if got := buf.String(); got != tt.want {
+				t.Errorf("printBisectCulprit() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// newBisectRepo makes a repository whose master has merged two pull
+// requests onto the commit tagged good, and changes into it. The second one
+// breaks test.txt.
+func newBisectRepo(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(wd)
+	})
+	setenv(t, "HOME", dir)
+	setenv(t, "GIT_CONFIG_NOSYSTEM", "1")
+	setenv(t, "GIT_AUTHOR_NAME", "Alice")
+	setenv(t, "GIT_AUTHOR_EMAIL", "alice@example.com")
+	setenv(t, "GIT_COMMITTER_NAME", "Alice")
+	setenv(t, "GIT_COMMITTER_EMAIL", "alice@example.com")
+	git := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		git("add", name)
+	}
+	git("init", "-q")
+	git("symbolic-ref", "HEAD", "refs/heads/master")
+	write("test.txt", "ok\n")
+	git("commit", "-q", "-m", "Initial commit")
+	git("tag", "good")
+	for i, change := range []struct{ name, content string }{
+		{"a.txt", "a\n"},
+		{"test.txt", "ng\n"},
+	} {
+		branch := "feature/" + change.name
+		git("checkout", "-q", "-b", branch, "master")
+		write(change.name, change.content)
+		git("commit", "-q", "-m", "Change "+change.name)
+		git("checkout", "-q", "master")
+		git("merge", "-q", "--no-ff", "-m", "Merge pull request #"+strconv.Itoa(i+1)+" "+branch+" into master", branch)
+	}
+}
+
+func TestBacklogRepository_BisectPR(t *testing.T) {
+	setenv(t, apiKeyEnv, "")
+	setenv(t, "XDG_CONFIG_HOME", t.TempDir())
+	newBisectRepo(t)
+	b := &BacklogRepository{
+		repo:       &RepositoryMock{GitFunc: workingDirGit},
+		domain:     "backlog.com",
+		spaceKey:   "foo",
+		projectKey: "BAR",
+		repoName:   "baz",
+	}
+	var buf bytes.Buffer
+	if err := b.BisectPR(&buf, "good", "master", []string{"grep", "-q", "ok", "test.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "The first bad pull request is PR #2\n"; !strings.HasPrefix(got, want) {
+		t.Errorf("BisectPR() printed\n%s\nwant it to start with %q", got, want)
+	}
+	if b.bisecting() {
+		t.Error("BisectPR() left the bisect in progress")
+	}
+}
+
+func TestBacklogRepository_BisectPR_inProgress(t *testing.T) {
+	setenv(t, apiKeyEnv, "")
+	setenv(t, "XDG_CONFIG_HOME", t.TempDir())
+	newBisectRepo(t)
+	for _, args := range [][]string{{"start"}, {"bad", "master"}} {
+		if out, err := exec.Command("git", append([]string{"bisect"}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git bisect %v: %v\n%s", args, err, out)
+		}
+	}
+	b := &BacklogRepository{
+		repo:       &RepositoryMock{GitFunc: workingDirGit},
+		domain:     "backlog.com",
+		spaceKey:   "foo",
+		projectKey: "BAR",
+		repoName:   "baz",
+	}
+	var buf bytes.Buffer
+	if err := b.BisectPR(&buf, "good", "master", []string{"true"}); err == nil {
+		t.Fatal("BisectPR() succeeded during another bisect")
+	}
+	if _, err := b.runGit("rev-parse", "-q", "--verify", "refs/bisect/bad"); err != nil {
+		t.Error("BisectPR() reset the bisect in progress")
+	}
+}
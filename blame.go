@@ -0,0 +1,834 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vvatanabe/gitb/internal/backlog"
+)
+
+// defaultMergePatterns match the subjects of the commits made by merging pull
+// requests, with the ID of the pull request as the first group.
+var defaultMergePatterns = []string{
+	// Merge commits of Backlog.
+	`^Merge pull request #([0-9]+) `,
+	// Merge commits of Backlog in Japanese.
+	`^プルリクエスト\s*#([0-9]+)\s*\S*\s*をマージ`,
+	// Squash merges titled with the ID.
+	`\(#([0-9]+)\)$`,
+}
+
+// mergePatternConfigKey adds a pattern to defaultMergePatterns.
+const mergePatternConfigKey = "pr.mergepattern"
+
+// BlameFormat is how gitb pr blame prints lines: like git blame by default,
+// or as JSON or tab-separated fields for scripting.
+type BlameFormat struct {
+	JSON      bool
+	Porcelain bool
+	// Color groups the consecutive lines of a pull request with colors, and
+	// links the pull requests with OSC 8 hyperlinks.
+	Color bool
+	// Summary adds the summaries and statuses of issues to gitb issue blame.
+	Summary bool
+}
+
+// BlamePR shows the pull request which brought each line of a file. The
+// porcelain output of git blame is parsed in a single pass, and the pull
+// requests found are cached in the git directory.
+func (b *BacklogRepository) BlamePR(argv []string, f *BlameFormat) error {
+	resolver, err := b.newPRResolver()
+	if err != nil {
+		return err
+	}
+	lines, err := runBlame(argv)
+	if err != nil {
+		return err
+	}
+	ids, err := b.resolvePullRequests(resolver, lines)
+	if err != nil {
+		return err
+	}
+	return printBlame(os.Stdout, blameRecords(lines, ids, b.projectKey), f, func(r *BlameRecord) (string, string) {
+		if r.PullRequest > 0 {
+			return fmt.Sprintf("PR #%d", r.PullRequest), b.urlBuilder().PullRequestURL(strconv.Itoa(r.PullRequest))
+		}
+		return commitLabel(r), ""
+	})
+}
+
+// runBlame runs git blame on the first-parent history with argv.
+func runBlame(argv []string) ([]*blameLine, error) {
+	argv = append([]string{"blame", "--porcelain", "--first-parent"}, argv...)
+	cmd := exec.CommandContext(context.Background(), "git", argv...)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	lines, err := parseBlamePorcelain(stdout)
+	if err != nil {
+		_ = cmd.Wait()
+		return nil, err
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// resolvePullRequests returns the pull requests of the commits of lines,
+// with the cache in the git directory.
+func (b *BacklogRepository) resolvePullRequests(resolver *prResolver, lines []*blameLine) (map[string]int, error) {
+	cacheFile := b.prCacheFile()
+	cache := loadPRCache(cacheFile, resolver.signature())
+	for hash, id := range cache.ids {
+		resolver.cache[hash] = id
+	}
+	var commits []string
+	for _, l := range lines {
+		commits = append(commits, l.Commit.Hash)
+	}
+	ids, err := resolver.resolveAll(commits)
+	if err != nil {
+		return nil, err
+	}
+	if cache.add(ids) && cacheFile != "" {
+		// The cache only saves time, so a failure to write it is not fatal.
+		_ = cache.save(cacheFile)
+	}
+	return ids, nil
+}
+
+// blameCommit is a commit in the porcelain output of git blame.
+type blameCommit struct {
+	Hash     string
+	Author   string
+	Time     time.Time
+	Summary  string
+	Boundary bool
+}
+
+// blameLine is a line of the file blamed.
+type blameLine struct {
+	Commit *blameCommit
+	LineNo int
+	Text   string
+}
+
+// parseBlamePorcelain parses the output of git blame --porcelain, in which
+// the headers of a commit are only given at its first line.
+func parseBlamePorcelain(r io.Reader) ([]*blameLine, error) {
+	var (
+		lines   []*blameLine
+		cur     *blameLine
+		commits = make(map[string]*blameCommit)
+	)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if cur == nil {
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				return nil, errors.Errorf("unexpected line of git blame: %q", line)
+			}
+			lineNo, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return nil, errors.Errorf("unexpected line of git blame: %q", line)
+			}
+			c, ok := commits[fields[0]]
+			if !ok {
+				c = &blameCommit{Hash: fields[0]}
+				commits[c.Hash] = c
+			}
+			cur = &blameLine{Commit: c, LineNo: lineNo}
+			continue
+		}
+		if strings.HasPrefix(line, "\t") {
+			cur.Text = line[1:]
+			lines = append(lines, cur)
+			cur = nil
+			continue
+		}
+		kv := strings.SplitN(line, " ", 2)
+		if len(kv) < 2 {
+			kv = append(kv, "")
+		}
+		switch kv[0] {
+		case "author":
+			cur.Commit.Author = kv[1]
+		case "author-time":
+			sec, _ := strconv.ParseInt(kv[1], 10, 64)
+			cur.Commit.Time = time.Unix(sec, 0).In(cur.Commit.Time.Location())
+		case "author-tz":
+			cur.Commit.Time = cur.Commit.Time.In(parseTimeZone(kv[1]))
+		case "summary":
+			cur.Commit.Summary = kv[1]
+		case "boundary":
+			cur.Commit.Boundary = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// parseTimeZone parses a time zone of git such as "+0900".
+func parseTimeZone(s string) *time.Location {
+	t, err := time.Parse("-0700", s)
+	if err != nil {
+		return time.UTC
+	}
+	return t.Location()
+}
+
+// BlameRecord is a line of the file blamed, with the pull request which
+// brought it.
+type BlameRecord struct {
+	Commit      string   `json:"commit"`
+	Boundary    bool     `json:"boundary,omitempty"`
+	PullRequest int      `json:"pullRequest,omitempty"`
+	IssueKeys   []string `json:"issueKeys"`
+	// IssueSummary and IssueStatus are only given by gitb issue blame --summary.
+	IssueSummary string    `json:"issueSummary,omitempty"`
+	IssueStatus  string    `json:"issueStatus,omitempty"`
+	Author       string    `json:"author"`
+	Time         time.Time `json:"time"`
+	Line         int       `json:"line"`
+	Content      string    `json:"content"`
+}
+
+// blameRecords joins lines with the pull requests in ids. The issue keys of
+// the project are taken from the summary of the commit, which has the branch
+// name when it is a merge.
+func blameRecords(lines []*blameLine, ids map[string]int, projectKey string) []*BlameRecord {
+	records := make([]*BlameRecord, 0, len(lines))
+	for _, l := range lines {
+		keys := extractProjectIssueKeys(l.Commit.Summary, projectKey)
+		if keys == nil {
+			keys = []string{}
+		}
+		records = append(records, &BlameRecord{
+			Commit:      l.Commit.Hash,
+			Boundary:    l.Commit.Boundary,
+			PullRequest: ids[l.Commit.Hash],
+			IssueKeys:   keys,
+			Author:      l.Commit.Author,
+			Time:        l.Commit.Time,
+			Line:        l.LineNo,
+			Content:     l.Text,
+		})
+	}
+	return records
+}
+
+// printBlame prints records in the format f. label returns the text shown
+// for a record in place of the commit, and the URL it links to.
+func printBlame(w io.Writer, records []*BlameRecord, f *BlameFormat, label func(r *BlameRecord) (string, string)) error {
+	switch {
+	case f.JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	case f.Porcelain:
+		for _, r := range records {
+			pr := ""
+			if r.PullRequest > 0 {
+				pr = strconv.Itoa(r.PullRequest)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", r.Commit, pr, strings.Join(r.IssueKeys, ","), r.Author, r.Line, r.Content)
+		}
+		return nil
+	}
+
+	labels := make([]string, len(records))
+	urls := make([]string, len(records))
+	var labelWidth, authorWidth, lineWidth int
+	for i, r := range records {
+		labels[i], urls[i] = label(r)
+		labelWidth = max(labelWidth, displayWidth(labels[i]))
+		authorWidth = max(authorWidth, displayWidth(r.Author))
+		lineWidth = max(lineWidth, len(strconv.Itoa(r.Line)))
+	}
+	group := 0
+	for i, r := range records {
+		padding := strings.Repeat(" ", labelWidth-displayWidth(labels[i]))
+		text := labels[i] + padding
+		info := fmt.Sprintf("(%s%s %s %*d)", r.Author, strings.Repeat(" ", authorWidth-displayWidth(r.Author)),
+			r.Time.Format("2006-01-02 15:04:05 -0700"), lineWidth, r.Line)
+		if f.Color {
+			if i > 0 && labels[i] == labels[i-1] {
+				// The label is only shown at the first line of a group.
+				text = strings.Repeat(" ", labelWidth)
+			} else {
+				group++
+				color := colorYellow
+				if group%2 == 0 {
+					color = colorCyan
+				}
+				text = labels[i]
+				if urls[i] != "" {
+					text = hyperlink(text, urls[i])
+				}
+				text = colorize(text, color, true) + padding
+			}
+			info = colorize(info, colorGray, true)
+		}
+		fmt.Fprintf(w, "%s %s %s\n", text, info, r.Content)
+	}
+	return nil
+}
+
+// commitLabel is the abbreviated commit of r, marked with ^ at the boundary
+// like git blame.
+func commitLabel(r *BlameRecord) string {
+	if r.Boundary {
+		return "^" + abbrev(r.Commit, 7)
+	}
+	return abbrev(r.Commit, 8)
+}
+
+func abbrev(hash string, n int) string {
+	if len(hash) > n {
+		return hash[:n]
+	}
+	return hash
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// prResolver finds the pull request which brought a commit. It tries the
+// merge patterns and the pull requests of Backlog API with the commit, and
+// then with the first-parent merge which introduced the commit.
+type prResolver struct {
+	// repoURL is the URL of the repository whose pull requests are found.
+	repoURL  string
+	patterns []*regexp.Regexp
+	// fetchPRs returns count merged pull requests from offset, newest first.
+	// It is nil without Backlog API.
+	fetchPRs func(offset, count int) ([]*backlog.PullRequest, error)
+	// prs are the merged pull requests fetched so far, and fetchedAll is
+	// true when there are no more.
+	prs        []*backlog.PullRequest
+	fetchedAll bool
+	// git runs git and returns its stdout.
+	git func(args ...string) (string, error)
+	// head is the revision whose history is looked at, or HEAD when empty.
+	head string
+	// firstParents maps the commits on the first-parent history of head to
+	// their subjects.
+	firstParents map[string]string
+	cache        map[string]int
+}
+
+func compileMergePatterns(extra string) ([]*regexp.Regexp, error) {
+	patterns := defaultMergePatterns
+	if extra != "" {
+		patterns = append([]string{extra}, patterns...)
+	}
+	var res []*regexp.Regexp
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s", mergePatternConfigKey)
+		}
+		if re.NumSubexp() < 1 {
+			return nil, errors.Errorf("%s must have a group of the PR ID: %s", mergePatternConfigKey, p)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+func (b *BacklogRepository) newPRResolver() (*prResolver, error) {
+	patterns, err := compileMergePatterns(b.config.Get(mergePatternConfigKey))
+	if err != nil {
+		return nil, err
+	}
+	r := &prResolver{
+		repoURL:  b.urlBuilder().GitRepoBaseURL(),
+		patterns: patterns,
+		git:      b.runGit,
+		cache:    make(map[string]int),
+	}
+	if client, err := b.APIClient(); err == nil {
+		r.fetchPRs = func(offset, count int) ([]*backlog.PullRequest, error) {
+			return client.GetPullRequests(context.Background(), b.projectKey, b.repoName, &backlog.PullRequestListOptions{
+				StatusIDs: []int{backlog.PullRequestStatusMerged},
+				Offset:    offset,
+				Limit:     count,
+			})
+		}
+	}
+	return r, nil
+}
+
+// runGit runs git in the repository and returns its stdout.
+func (b *BacklogRepository) runGit(args ...string) (string, error) {
+	out, err := b.repo.Git(args...).Output()
+	return string(out), err
+}
+
+// signature identifies the repository and the patterns of the resolver, so
+// that the pull requests cached for another space, project or repository, or
+// with other patterns, are not reused.
+func (r *prResolver) signature() string {
+	h := sha1.New()
+	fmt.Fprintln(h, r.repoURL)
+	for _, re := range r.patterns {
+		fmt.Fprintln(h, re.String())
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// setHead makes r look at the history of head, forgetting the commits
+// resolved on the previous one.
+func (r *prResolver) setHead(head string) {
+	r.head = head
+	r.firstParents = nil
+	r.cache = make(map[string]int)
+}
+
+func (r *prResolver) headRevision() string {
+	if r.head == "" {
+		return "HEAD"
+	}
+	return r.head
+}
+
+// resolveAll resolves each of commits once. The subjects of the
+// first-parent history are read by a single git log for all of them.
+func (r *prResolver) resolveAll(commits []string) (map[string]int, error) {
+	ids := make(map[string]int)
+	for _, c := range commits {
+		if _, ok := ids[c]; ok {
+			continue
+		}
+		id, err := r.resolve(c)
+		if err != nil {
+			return nil, err
+		}
+		ids[c] = id
+	}
+	return ids, nil
+}
+
+// resolve returns the ID of the pull request which brought commit, or 0 when
+// it is not found.
+func (r *prResolver) resolve(commit string) (int, error) {
+	commit = strings.TrimPrefix(commit, "^")
+	if strings.Trim(commit, "0") == "" {
+		// Not committed yet.
+		return 0, nil
+	}
+	if id, ok := r.cache[commit]; ok {
+		return id, nil
+	}
+	if err := r.loadFirstParents(); err != nil {
+		return 0, err
+	}
+	hash, id, err := r.resolveCommit(commit)
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := r.firstParents[hash]; id == 0 && !ok {
+		merge, err := r.introducingMerge(hash)
+		if err != nil {
+			return 0, err
+		}
+		if merge != "" {
+			if _, id, err = r.resolveCommit(merge); err != nil {
+				return 0, err
+			}
+		}
+	}
+	r.cache[commit] = id
+	return id, nil
+}
+
+// resolveCommit returns the full hash of commit and the ID of the pull
+// request which the commit merged.
+func (r *prResolver) resolveCommit(commit string) (string, int, error) {
+	hash, subject := commit, ""
+	if s, ok := r.firstParents[commit]; ok {
+		subject = s
+	} else {
+		out, err := r.git("show", "-s", "--format=%H%x00%s", commit)
+		if err != nil {
+			return "", 0, errors.Wrapf(err, "could not show %s", commit)
+		}
+		fields := strings.SplitN(strings.TrimSpace(out), "\x00", 2)
+		if len(fields) < 2 {
+			return "", 0, errors.Errorf("could not show %s", commit)
+		}
+		hash, subject = fields[0], fields[1]
+	}
+	for _, re := range r.patterns {
+		if m := re.FindStringSubmatch(subject); len(m) > 1 {
+			if id, err := strconv.Atoi(m[1]); err == nil {
+				return hash, id, nil
+			}
+		}
+	}
+	pr, err := r.findPullRequest(func(pr *backlog.PullRequest) bool {
+		return pr.MergeCommit == hash || pr.BranchCommit == hash
+	})
+	if err != nil || pr == nil {
+		return hash, 0, err
+	}
+	return hash, pr.Number, nil
+}
+
+// findPullRequest returns the merged pull request which matches, or nil when
+// there is none. The pull requests are fetched a page at a time, and only
+// until one matches.
+func (r *prResolver) findPullRequest(match func(*backlog.PullRequest) bool) (*backlog.PullRequest, error) {
+	for i := 0; ; {
+		for ; i < len(r.prs); i++ {
+			if match(r.prs[i]) {
+				return r.prs[i], nil
+			}
+		}
+		if r.fetchPRs == nil || r.fetchedAll {
+			return nil, nil
+		}
+		page, err := r.fetchPRs(len(r.prs), pullRequestPageSize)
+		if err != nil {
+			return nil, err
+		}
+		r.prs = append(r.prs, page...)
+		r.fetchedAll = len(page) < pullRequestPageSize
+	}
+}
+
+func (r *prResolver) loadFirstParents() error {
+	if r.firstParents != nil {
+		return nil
+	}
+	out, err := r.git("log", "--first-parent", "--format=%H%x00%s", r.headRevision())
+	if err != nil {
+		return errors.Wrap(err, "could not read the history")
+	}
+	r.firstParents = make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, "\x00", 2)
+		if len(fields) == 2 {
+			r.firstParents[fields[0]] = fields[1]
+		}
+	}
+	return nil
+}
+
+// introducingMerge returns the oldest merge on the first-parent history of
+// head which has hash as an ancestor, or empty when there is none.
+func (r *prResolver) introducingMerge(hash string) (string, error) {
+	out, err := r.git("rev-list", "--ancestry-path", "--merges", "--reverse", hash+".."+r.headRevision())
+	if err != nil {
+		return "", err
+	}
+	for _, v := range strings.Fields(out) {
+		if _, ok := r.firstParents[v]; ok {
+			return v, nil
+		}
+	}
+	return "", nil
+}
+
+// prCache maps commits to the pull requests which brought them. Commits
+// without pull requests are not cached, since a pull request merged later
+// or found with Backlog API may bring them.
+type prCache struct {
+	signature string
+	ids       map[string]int
+}
+
+// prCacheFile is in the common git directory, shared by the worktrees.
+func (b *BacklogRepository) prCacheFile() string {
+	dir, err := b.gitPath("rev-parse", "--git-common-dir")
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gitb", "pr-cache")
+}
+
+// gitPath runs git which prints a path, and returns it as an absolute path.
+// A relative one is of the directory git ran in.
+func (b *BacklogRepository) gitPath(args ...string) (string, error) {
+	cmd := b.repo.Git(args...)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	path := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cmd.Dir, path)
+	}
+	return filepath.Abs(path)
+}
+
+// loadPRCache reads file. The cache is empty when file does not exist or was
+// written with another signature.
+func loadPRCache(file, signature string) *prCache {
+	c := &prCache{signature: signature, ids: make(map[string]int)}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return c
+	}
+	lines := strings.Split(string(b), "\n")
+	if lines[0] != "# "+signature {
+		return c
+	}
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if id, err := strconv.Atoi(fields[1]); err == nil && id > 0 {
+			c.ids[fields[0]] = id
+		}
+	}
+	return c
+}
+
+// add adds the commits of ids with pull requests, and reports whether any
+// of them was new.
+func (c *prCache) add(ids map[string]int) bool {
+	added := false
+	for hash, id := range ids {
+		if _, ok := c.ids[hash]; id > 0 && !ok {
+			c.ids[hash] = id
+			added = true
+		}
+	}
+	return added
+}
+
+func (c *prCache) save(file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	hashes := make([]string, 0, len(c.ids))
+	for h := range c.ids {
+		hashes = append(hashes, h)
+	}
+	sort.Strings(hashes)
+	var sb strings.Builder
+	sb.WriteString("# " + c.signature + "\n")
+	for _, h := range hashes {
+		fmt.Fprintf(&sb, "%s %d\n", h, c.ids[h])
+	}
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(sb.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// issueSummaryWidth is the width of the summaries of issues in the labels
+// of gitb issue blame.
+const issueSummaryWidth = 30
+
+// BlameIssue shows the issue which changed each line of a file. With
+// f.Summary, the summaries and statuses of the issues are fetched by Backlog
+// API.
+func (b *BacklogRepository) BlameIssue(argv []string, f *BlameFormat) error {
+	var client *backlog.Client
+	if f.Summary {
+		c, err := b.APIClient()
+		if err != nil {
+			return err
+		}
+		client = c
+	}
+	prs, err := b.newPRResolver()
+	if err != nil {
+		return err
+	}
+	lines, err := runBlame(argv)
+	if err != nil {
+		return err
+	}
+	ids, err := b.resolvePullRequests(prs, lines)
+	if err != nil {
+		return err
+	}
+	resolver := &issueResolver{git: b.runGit, prs: prs, projectKey: b.projectKey}
+	keys, err := resolver.resolveAll(lines, ids)
+	if err != nil {
+		return err
+	}
+	records := blameRecords(lines, ids, b.projectKey)
+	issues := make(map[string]*backlog.Issue)
+	for _, r := range records {
+		key := keys[r.Commit]
+		if key == "" {
+			r.IssueKeys = []string{}
+			continue
+		}
+		r.IssueKeys = []string{key}
+		if client == nil {
+			continue
+		}
+		issue, ok := issues[key]
+		if !ok {
+			issue, err = client.GetIssue(context.Background(), key)
+			if err != nil && !backlog.IsNotFound(err) {
+				return err
+			}
+			// An issue not found, e.g. of another space, is shown only with its key.
+			issues[key] = issue
+		}
+		if issue != nil {
+			r.IssueSummary = issue.Summary
+			if issue.Status != nil {
+				r.IssueStatus = issue.Status.Name
+			}
+		}
+	}
+	return printBlame(os.Stdout, records, f, func(r *BlameRecord) (string, string) {
+		if len(r.IssueKeys) == 0 {
+			return commitLabel(r), ""
+		}
+		return issueLabel(r), b.urlBuilder().IssueURL(r.IssueKeys[0])
+	})
+}
+
+func issueLabel(r *BlameRecord) string {
+	label := r.IssueKeys[0]
+	if r.IssueStatus != "" {
+		label += " [" + r.IssueStatus + "]"
+	}
+	if r.IssueSummary != "" {
+		label += " " + truncate(r.IssueSummary, issueSummaryWidth)
+	}
+	return label
+}
+
+// issueResolver finds the issue key of a commit in its message, in the
+// source branch and the issue of its pull request, and in the messages of
+// the commits merged by it.
+type issueResolver struct {
+	// git runs git and returns its stdout.
+	git func(args ...string) (string, error)
+	prs *prResolver
+	// projectKey is the key of the project whose issues are looked for.
+	projectKey string
+}
+
+type commitMessage struct {
+	merge   bool
+	message string
+}
+
+// resolveAll returns the issue keys of the commits of lines, reading their
+// messages with a single git show. ids are the pull requests of the commits.
+func (r *issueResolver) resolveAll(lines []*blameLine, ids map[string]int) (map[string]string, error) {
+	var commits []string
+	seen := make(map[string]bool)
+	for _, l := range lines {
+		c := l.Commit.Hash
+		if !seen[c] && strings.Trim(c, "0") != "" {
+			seen[c] = true
+			commits = append(commits, c)
+		}
+	}
+	keys := make(map[string]string)
+	if len(commits) == 0 {
+		return keys, nil
+	}
+	out, err := r.git(append([]string{"show", "-s", "--format=%H%x00%P%x00%B%x1e"}, commits...)...)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the commit messages")
+	}
+	messages := make(map[string]*commitMessage)
+	for _, record := range strings.Split(out, "\x1e") {
+		fields := strings.SplitN(strings.TrimLeft(record, "\n"), "\x00", 3)
+		if len(fields) == 3 {
+			messages[fields[0]] = &commitMessage{
+				merge:   len(strings.Fields(fields[1])) > 1,
+				message: fields[2],
+			}
+		}
+	}
+	for _, c := range commits {
+		m, ok := messages[c]
+		if !ok {
+			return nil, errors.Errorf("could not read the message of %s", c)
+		}
+		key, err := r.resolve(c, m, ids[c])
+		if err != nil {
+			return nil, err
+		}
+		keys[c] = key
+	}
+	return keys, nil
+}
+
+func (r *issueResolver) resolve(commit string, m *commitMessage, pr int) (string, error) {
+	if key := extractProjectIssueKey(m.message, r.projectKey); key != "" {
+		return key, nil
+	}
+	if pr > 0 {
+		p, err := r.prs.findPullRequest(func(p *backlog.PullRequest) bool {
+			return p.Number == pr
+		})
+		if err != nil {
+			return "", err
+		}
+		if p != nil {
+			if p.Issue != nil && p.Issue.IssueKey != "" {
+				return p.Issue.IssueKey, nil
+			}
+			if key := extractProjectIssueKey(p.Branch, r.projectKey); key != "" {
+				return key, nil
+			}
+		}
+	}
+	if m.merge {
+		out, err := r.git("log", "--format=%B", commit+"^1.."+commit)
+		if err != nil {
+			return "", errors.Wrapf(err, "could not read the commits merged by %s", commit)
+		}
+		return extractProjectIssueKey(out, r.projectKey), nil
+	}
+	return "", nil
+}
+
+// landings finds the pull request which brought commit into each of refs.
+func (r *prResolver) landings(commit string, refs []string) ([]*CommitLanding, error) {
+	var landings []*CommitLanding
+	for _, ref := range refs {
+		r.setHead(ref)
+		id, err := r.resolve(commit)
+		if err != nil {
+			return nil, err
+		}
+		landings = append(landings, &CommitLanding{Branch: ref, PullRequest: id})
+	}
+	return landings, nil
+}
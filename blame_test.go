@@ -0,0 +1,621 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vvatanabe/gitb/internal/backlog"
+)
+
+func Test_compileMergePatterns(t *testing.T) {
+	tests := []struct {
+		name    string
+		extra   string
+		want    int
+		wantErr bool
+	}{
+		{name: "default", want: len(defaultMergePatterns)},
+		{name: "extra", extra: `^Merged !([0-9]+)`, want: len(defaultMergePatterns) + 1},
+		{name: "invalid", extra: `([0-9]+`, wantErr: true},
+		{name: "no group", extra: `^Merged`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := compileMergePatterns(tt.extra)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("compileMergePatterns() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.want {
+				t.Errorf("compileMergePatterns() = %d patterns, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+// fakeGit answers git show with subjects, git log with firstParents, and
+// git rev-list with the merges of ancestryPaths.
+func fakeGit(subjects map[string]string, firstParents []string, ancestryPaths map[string][]string) func(args ...string) (string, error) {
+	return func(args ...string) (string, error) {
+		switch {
+		case args[0] == "show":
+			commit := args[len(args)-1]
+			for hash, subject := range subjects {
+				if strings.HasPrefix(hash, commit) {
+					return hash + "\x00" + subject + "\n", nil
+				}
+			}
+			return "", errors.New("unknown revision " + commit)
+		case args[0] == "log" && args[1] == "--first-parent":
+			var out string
+			for _, hash := range firstParents {
+				out += hash + "\x00" + subjects[hash] + "\n"
+			}
+			return out, nil
+		case args[0] == "rev-list" && args[1] == "--ancestry-path":
+			commit := strings.SplitN(args[len(args)-1], "..", 2)[0]
+			return strings.Join(ancestryPaths[commit], "\n"), nil
+		}
+		return "", errors.Errorf("unexpected git %v", args)
+	}
+}
+
+func Test_prResolver_resolve(t *testing.T) {
+	subjects := map[string]string{
+		"m1": "Merge pull request #12 from feature/a into master",
+		"m2": "プルリクエスト #34 feature/b をマージ",
+		"s1": "Add the list command (#56)",
+		"f1": "Fix a typo",
+		"b1": "Work on feature/c",
+		"b2": "Work on feature/d",
+		"m3": "Merge branch 'feature/d'",
+		"c1": "Initial commit",
+		"x1": "Merged !78",
+	}
+	firstParents := []string{"x1", "m3", "s1", "m2", "m1", "f1", "c1"}
+	ancestryPaths := map[string][]string{
+		"b1": {"m1", "m2"},
+		"b2": {"m3"},
+	}
+	tests := []struct {
+		name    string
+		commit  string
+		prs     []*backlog.PullRequest
+		extra   string
+		want    int
+		wantErr bool
+	}{
+		{name: "merge", commit: "m1", want: 12},
+		{name: "boundary", commit: "^m1", want: 12},
+		{name: "japanese merge", commit: "m2", want: 34},
+		{name: "squash", commit: "s1", want: 56},
+		{name: "configured pattern", commit: "x1", extra: `^Merged !([0-9]+)`, want: 78},
+		{name: "direct commit", commit: "f1", want: 0},
+		{name: "commit of a branch", commit: "b1", want: 12},
+		{name: "fast-forward with api", commit: "f1", prs: []*backlog.PullRequest{{Number: 90, BranchCommit: "f1"}}, want: 90},
+		{name: "merge commit with api", commit: "m3", prs: []*backlog.PullRequest{{Number: 91, MergeCommit: "m3"}}, want: 91},
+		{name: "branch merged without pattern", commit: "b2", want: 0},
+		{name: "branch merged with api", commit: "b2", prs: []*backlog.PullRequest{{Number: 91, MergeCommit: "m3"}}, want: 91},
+		{name: "not committed", commit: "00000000", want: 0},
+		{name: "unknown", commit: "zz", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patterns, err := compileMergePatterns(tt.extra)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := &prResolver{
+				patterns: patterns,
+				git:      fakeGit(subjects, firstParents, ancestryPaths),
+				cache:    make(map[string]int),
+			}
+			if tt.prs != nil {
+				r.fetchPRs = func(offset, count int) ([]*backlog.PullRequest, error) {
+					if offset > 0 {
+						return nil, nil
+					}
+					return tt.prs, nil
+				}
+			}
+			got, err := r.resolve(tt.commit)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolve() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_prResolver_resolveAll(t *testing.T) {
+	subjects := map[string]string{
+		"m1": "Merge pull request #12 feature/a into master",
+		"f1": "Fix a typo",
+		"c1": "Initial commit",
+	}
+	git := fakeGit(subjects, []string{"m1", "f1", "c1"}, nil)
+	var calls []string
+	patterns, err := compileMergePatterns("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetched := 0
+	r := &prResolver{
+		patterns: patterns,
+		fetchPRs: func(offset, count int) ([]*backlog.PullRequest, error) {
+			fetched++
+			return nil, nil
+		},
+		git: func(args ...string) (string, error) {
+			calls = append(calls, args[0])
+			return git(args...)
+		},
+		cache: map[string]int{"c1": 3},
+	}
+	got, err := r.resolveAll([]string{"m1", "f1", "m1", "c1", "f1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"m1": 12, "f1": 0, "c1": 3}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resolveAll() = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(calls, []string{"log"}) {
+		t.Errorf("resolveAll() ran git %v, want a single git log", calls)
+	}
+	if fetched != 1 {
+		t.Errorf("resolveAll() fetched the pull requests %d times, want 1", fetched)
+	}
+}
+
+func Test_prResolver_findPullRequest(t *testing.T) {
+	defer func(size int) { pullRequestPageSize = size }(pullRequestPageSize)
+	pullRequestPageSize = 2
+	prs := []*backlog.PullRequest{
+		{Number: 9, MergeCommit: "m9"},
+		{Number: 8, MergeCommit: "m8"},
+		{Number: 7, MergeCommit: "m7"},
+		{Number: 6, MergeCommit: "m6"},
+		{Number: 5, MergeCommit: "m5"},
+	}
+	var offsets []int
+	r := &prResolver{
+		fetchPRs: func(offset, count int) ([]*backlog.PullRequest, error) {
+			offsets = append(offsets, offset)
+			end := offset + count
+			if end > len(prs) {
+				end = len(prs)
+			}
+			return prs[offset:end], nil
+		},
+	}
+	tests := []struct {
+		commit      string
+		want        int
+		wantOffsets []int
+	}{
+		{commit: "m8", want: 8, wantOffsets: []int{0}},
+		{commit: "m7", want: 7, wantOffsets: []int{0, 2}},
+		{commit: "m9", want: 9, wantOffsets: []int{0, 2}},
+		{commit: "m1", want: 0, wantOffsets: []int{0, 2, 4}},
+		{commit: "m2", want: 0, wantOffsets: []int{0, 2, 4}},
+	}
+	for _, tt := range tests {
+		pr, err := r.findPullRequest(func(pr *backlog.PullRequest) bool {
+			return pr.MergeCommit == tt.commit
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := 0
+		if pr != nil {
+			got = pr.Number
+		}
+		if got != tt.want {
+			t.Errorf("findPullRequest(%s) = %d, want %d", tt.commit, got, tt.want)
+		}
+		if !reflect.DeepEqual(offsets, tt.wantOffsets) {
+			t.Errorf("findPullRequest(%s) fetched the pages at %v, want %v", tt.commit, offsets, tt.wantOffsets)
+		}
+	}
+}
+
+const testBlamePorcelain = `1111111111111111111111111111111111111111 1 1 2
+author Alice
+author-mail <alice@example.com>
+author-time 1600000000
+author-tz +0900
+committer Alice
+committer-mail <alice@example.com>
+committer-time 1600000000
+committer-tz +0900
+summary Initial commit
+boundary
+filename main.go
+	package main
+1111111111111111111111111111111111111111 2 2
+	
+2222222222222222222222222222222222222222 3 3 1
+author Bob Smith
+author-mail <bob@example.com>
+author-time 1600086400
+author-tz -0500
+committer Bob Smith
+committer-mail <bob@example.com>
+committer-time 1600086400
+committer-tz -0500
+summary Merge pull request #7 feature/BAR-12 into master
+previous 1111111111111111111111111111111111111111 main.go
+filename main.go
+	func main() {}
+`
+
+func Test_parseBlamePorcelain(t *testing.T) {
+	got, err := parseBlamePorcelain(strings.NewReader(testBlamePorcelain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("parseBlamePorcelain() = %d lines, want 3", len(got))
+	}
+	if got[0].Commit != got[1].Commit {
+		t.Errorf("the first two lines should share the commit")
+	}
+	c := got[0].Commit
+	if c.Author != "Alice" || !c.Boundary || c.Time.Format(time.RFC3339) != "2020-09-13T21:26:40+09:00" {
+		t.Errorf("commit = %+v", c)
+	}
+	if l := got[2]; l.LineNo != 3 || l.Text != "func main() {}" || l.Commit.Author != "Bob Smith" || l.Commit.Boundary {
+		t.Errorf("line = %+v, commit = %+v", l, l.Commit)
+	}
+	if _, err := parseBlamePorcelain(strings.NewReader("fatal\n")); err == nil {
+		t.Error("parseBlamePorcelain() should fail on unexpected output")
+	}
+}
+
+func TestBacklogRepository_prCacheFile(t *testing.T) {
+	newGitRepo(t)
+	root, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The working directory is not the root of the repository.
+	if err := os.Mkdir("sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("sub"); err != nil {
+		t.Fatal(err)
+	}
+	b := &BacklogRepository{
+		repo: &RepositoryMock{
+			GitFunc: func(args ...string) *exec.Cmd {
+				cmd := exec.Command("git", args...)
+				cmd.Dir = root
+				return cmd
+			},
+		},
+	}
+	if got, want := b.prCacheFile(), filepath.Join(root, ".git", "gitb", "pr-cache"); got != want {
+		t.Errorf("BacklogRepository.prCacheFile() = %v, want %v", got, want)
+	}
+}
+
+func Test_blameRecords(t *testing.T) {
+	lines := []*blameLine{
+		{Commit: &blameCommit{Hash: "c1", Summary: "Support UTF-8 for ABC-1"}, LineNo: 1},
+		{Commit: &blameCommit{Hash: "m1", Summary: "Merge pull request #7 feature/BAR-12 into master"}, LineNo: 2},
+		{Commit: &blameCommit{Hash: "c2", Summary: "Fix BAR-3 and ABC-1, see SHA-256"}, LineNo: 3},
+	}
+	records := blameRecords(lines, map[string]int{"m1": 7}, "BAR")
+	var got [][]string
+	for _, r := range records {
+		got = append(got, r.IssueKeys)
+	}
+	want := [][]string{{}, {"BAR-12"}, {"BAR-3"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("blameRecords() issue keys = %v, want %v", got, want)
+	}
+	if records[1].PullRequest != 7 {
+		t.Errorf("blameRecords() pull request = %d, want 7", records[1].PullRequest)
+	}
+}
+
+func Test_printBlame(t *testing.T) {
+	lines, err := parseBlamePorcelain(strings.NewReader(testBlamePorcelain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := blameRecords(lines, map[string]int{"2222222222222222222222222222222222222222": 7}, "BAR")
+	label := func(r *BlameRecord) (string, string) {
+		if r.PullRequest > 0 {
+			return "PR #" + strconv.Itoa(r.PullRequest), "https://foo.backlog.com/git/BAR/baz/pullRequests/" + strconv.Itoa(r.PullRequest)
+		}
+		return commitLabel(r), ""
+	}
+	tests := []struct {
+		name   string
+		format *BlameFormat
+		want   string
+	}{
+		{
+			name:   "default",
+			format: &BlameFormat{},
+			want: `^1111111 (Alice     2020-09-13 21:26:40 +0900 1) package main
+^1111111 (Alice     2020-09-13 21:26:40 +0900 2) 
+PR #7    (Bob Smith 2020-09-14 07:26:40 -0500 3) func main() {}
+`,
+		},
+		{
+			name:   "color",
+			format: &BlameFormat{Color: true},
+			want: "\x1b[33m^1111111\x1b[0m \x1b[90m(Alice     2020-09-13 21:26:40 +0900 1)\x1b[0m package main\n" +
+				"         \x1b[90m(Alice     2020-09-13 21:26:40 +0900 2)\x1b[0m \n" +
+				"\x1b[36m\x1b]8;;https://foo.backlog.com/git/BAR/baz/pullRequests/7\x1b\\PR #7\x1b]8;;\x1b\\\x1b[0m    \x1b[90m(Bob Smith 2020-09-14 07:26:40 -0500 3)\x1b[0m func main() {}\n",
+		},
+		{
+			name:   "porcelain",
+			format: &BlameFormat{Porcelain: true},
+			want: "1111111111111111111111111111111111111111\t\t\tAlice\t1\tpackage main\n" +
+				"1111111111111111111111111111111111111111\t\t\tAlice\t2\t\n" +
+				"2222222222222222222222222222222222222222\t7\tBAR-12\tBob Smith\t3\tfunc main() {}\n",
+		},
+		{
+			name:   "json",
+			format: &BlameFormat{JSON: true},
+			want: `[
+  {
+    "commit": "1111111111111111111111111111111111111111",
+    "boundary": true,
+    "issueKeys": [],
+    "author": "Alice",
+    "time": "2020-09-13T21:26:40+09:00",
+    "line": 1,
+    "content": "package main"
+  },
+  {
+    "commit": "1111111111111111111111111111111111111111",
+    "boundary": true,
+    "issueKeys": [],
+    "author": "Alice",
+    "time": "2020-09-13T21:26:40+09:00",
+    "line": 2,
+    "content": ""
+  },
+  {
+    "commit": "2222222222222222222222222222222222222222",
+    "pullRequest": 7,
+    "issueKeys": [
+      "BAR-12"
+    ],
+    "author": "Bob Smith",
+    "time": "2020-09-14T07:26:40-05:00",
+    "line": 3,
+    "content": "func main() {}"
+  }
+]
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := printBlame(&buf, records, tt.format, label); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("printBlame() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_blameFormatAndArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		want     *BlameFormat
+		wantArgs []string
+	}{
+		{name: "git options", args: []string{"-L", "1,2", "main.go"}, want: &BlameFormat{}, wantArgs: []string{"-L", "1,2", "main.go"}},
+		{name: "json", args: []string{"--json", "-w", "main.go"}, want: &BlameFormat{JSON: true}, wantArgs: []string{"-w", "main.go"}},
+		{name: "porcelain", args: []string{"main.go", "--porcelain"}, want: &BlameFormat{Porcelain: true}, wantArgs: []string{"main.go"}},
+		{name: "summary", args: []string{"--summary", "main.go"}, want: &BlameFormat{Summary: true}, wantArgs: []string{"main.go"}},
+		{name: "after --", args: []string{"--", "--json"}, want: &BlameFormat{}, wantArgs: []string{"--", "--json"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotArgs := blameFormatAndArgs(tt.args)
+			if !reflect.DeepEqual(got, tt.want) || !reflect.DeepEqual(gotArgs, tt.wantArgs) {
+				t.Errorf("blameFormatAndArgs() = %+v, %v, want %+v, %v", got, gotArgs, tt.want, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func Test_prResolver_signature(t *testing.T) {
+	signature := func(spaceKey, projectKey, repoName string) string {
+		b := &BacklogRepository{
+			api:        backlog.NewClient("https://" + spaceKey + ".backlog.com"),
+			domain:     "backlog.com",
+			spaceKey:   spaceKey,
+			projectKey: projectKey,
+			repoName:   repoName,
+		}
+		r, err := b.newPRResolver()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r.signature()
+	}
+	want := signature("foo", "BAR", "baz")
+	if got := signature("foo", "BAR", "baz"); got != want {
+		t.Errorf("prResolver.signature() = %v, want %v of the same repository", got, want)
+	}
+	for _, other := range [][]string{{"qux", "BAR", "baz"}, {"foo", "QUX", "baz"}, {"foo", "BAR", "qux"}} {
+		if got := signature(other[0], other[1], other[2]); got == want {
+			t.Errorf("prResolver.signature() of %v = %v, the same as foo/BAR/baz", other, got)
+		}
+	}
+}
+
+func Test_prCache(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "gitb", "pr-cache")
+	c := loadPRCache(file, "sig")
+	if len(c.ids) != 0 {
+		t.Fatalf("loadPRCache() of no file = %v", c.ids)
+	}
+	if !c.add(map[string]int{"aaa": 1, "bbb": 0}) {
+		t.Error("add() should report the new commit")
+	}
+	if c.add(map[string]int{"aaa": 1, "ccc": 0}) {
+		t.Error("add() should not report known commits or commits without pull requests")
+	}
+	if err := c.save(file); err != nil {
+		t.Fatal(err)
+	}
+	if got := loadPRCache(file, "sig").ids; !reflect.DeepEqual(got, map[string]int{"aaa": 1}) {
+		t.Errorf("loadPRCache() = %v", got)
+	}
+	if got := loadPRCache(file, "other").ids; len(got) != 0 {
+		t.Errorf("loadPRCache() with another signature = %v", got)
+	}
+}
+
+func Test_issueLabel(t *testing.T) {
+	tests := []struct {
+		name   string
+		record *BlameRecord
+		want   string
+	}{
+		{name: "key", record: &BlameRecord{IssueKeys: []string{"BAR-1"}}, want: "BAR-1"},
+		{
+			name:   "summary",
+			record: &BlameRecord{IssueKeys: []string{"BAR-1"}, IssueStatus: "Open", IssueSummary: "Fix the login"},
+			want:   "BAR-1 [Open] Fix the login",
+		},
+		{
+			name:   "long summary",
+			record: &BlameRecord{IssueKeys: []string{"BAR-1"}, IssueStatus: "処理中", IssueSummary: "ログイン画面でパスワードを忘れた場合の導線を追加する"},
+			want:   "BAR-1 [処理中] ログイン画面でパスワードを忘…",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := issueLabel(tt.record); got != tt.want {
+				t.Errorf("issueLabel() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_issueResolver_resolveAll(t *testing.T) {
+	messages := map[string]string{
+		"c1": "c0\x00Fix BAR-1\n\nDetails\n",
+		"c2": "c1\x00Use UTF-8 in FOO-2\n\nFor BAR-2\n",
+		"m1": "c2 b1\x00Merge pull request #12 feature/a into master\n",
+		"m2": "m1 b2\x00Merge branch 'topic'\n",
+		"c3": "m2\x00Fix a typo\n\nSee RFC-3629 for UTF-8\n",
+	}
+	git := func(args ...string) (string, error) {
+		switch args[0] {
+		case "show":
+			var out string
+			for _, c := range args[3:] {
+				out += c + "\x00" + messages[c] + "\x1e\n"
+			}
+			return out, nil
+		case "log":
+			if args[2] == "m2^1..m2" {
+				return "Support SHA-256\n\nWork on BAR-9\n\nWork on BAR-8\n", nil
+			}
+			return "", nil
+		}
+		return "", errors.Errorf("unexpected git %v", args)
+	}
+	var lines []*blameLine
+	for _, c := range []string{"c1", "c2", "m1", "m2", "c3", "c1", "0000000000"} {
+		lines = append(lines, &blameLine{Commit: &blameCommit{Hash: c}})
+	}
+	r := &issueResolver{
+		git:        git,
+		projectKey: "BAR",
+		prs: &prResolver{
+			fetchPRs: func(offset, count int) ([]*backlog.PullRequest, error) {
+				if offset > 0 {
+					return nil, nil
+				}
+				return []*backlog.PullRequest{{Number: 12, Branch: "feature/BAR-3"}}, nil
+			},
+		},
+	}
+	got, err := r.resolveAll(lines, map[string]int{"m1": 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"c1": "BAR-1", "c2": "BAR-2", "m1": "BAR-3", "m2": "BAR-9", "c3": ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resolveAll() = %v, want %v", got, want)
+	}
+}
+
+func Test_prResolver_landings(t *testing.T) {
+	subjects := map[string]string{
+		"m1": "Merge pull request #12 feature/a into master",
+		"m2": "Merge pull request #34 feature/a into release/1.0",
+		"b1": "Work on feature/a",
+		"c1": "Initial commit",
+		"r1": "Cherry-pick the fix",
+	}
+	histories := map[string][]string{
+		"refs/remotes/origin/master":      {"m1", "c1"},
+		"refs/remotes/origin/release/1.0": {"m2", "r1", "c1"},
+		"refs/remotes/origin/feature/a":   {"b1", "c1"},
+	}
+	patterns, err := compileMergePatterns("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &prResolver{
+		patterns: patterns,
+		git: func(args ...string) (string, error) {
+			head := args[len(args)-1]
+			switch args[0] {
+			case "log":
+				return fakeGit(subjects, histories[head], nil)(args...)
+			case "rev-list":
+				// b1 was merged by the newest merge of each branch but feature/a.
+				head = strings.SplitN(head, "..", 2)[1]
+				if strings.HasSuffix(head, "/feature/a") {
+					return "", nil
+				}
+				return histories[head][0] + "\n", nil
+			}
+			return fakeGit(subjects, nil, nil)(args...)
+		},
+	}
+	got, err := r.landings("b1", []string{
+		"refs/remotes/origin/feature/a",
+		"refs/remotes/origin/master",
+		"refs/remotes/origin/release/1.0",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*CommitLanding{
+		{Branch: "refs/remotes/origin/feature/a", PullRequest: 0},
+		{Branch: "refs/remotes/origin/master", PullRequest: 12},
+		{Branch: "refs/remotes/origin/release/1.0", PullRequest: 34},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("landings() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// launcherTimeout is how long to wait for a launcher to fail. Launchers such
+// as xdg-open exit immediately, but browsers given by $BROWSER may keep
+// running until the window is closed, so they are left running after this.
+const launcherTimeout = 3 * time.Second
+
+// browserCommands returns the command templates to try in order: the
+// "browser" config value, the colon-separated list of $BROWSER, and then
+// the platform's default launchers.
+func browserCommands(configured string, getenv func(string) string, goos string, wsl bool) []string {
+	if configured != "" {
+		return []string{configured}
+	}
+	var commands []string
+	for _, v := range strings.Split(getenv("BROWSER"), ":") {
+		if v = strings.TrimSpace(v); v != "" {
+			commands = append(commands, v)
+		}
+	}
+	if len(commands) > 0 {
+		return commands
+	}
+	switch {
+	case goos == "darwin":
+		return []string{"open"}
+	case goos == "windows":
+		return []string{"rundll32 url.dll,FileProtocolHandler"}
+	case wsl:
+		return []string{"wslview", "xdg-open"}
+	}
+	return []string{"xdg-open"}
+}
+
+// isExecutable reports whether path is of a file, which is taken as an
+// executable.
+func isExecutable(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && !fi.IsDir()
+}
+
+// expandBrowserCommand replaces %s in the command template with url.
+// When the template has no %s, url is appended as the last argument.
+// A template without %s which is the path of an executable, e.g.
+// "/mnt/c/Program Files/Mozilla Firefox/firefox.exe", is not split.
+func expandBrowserCommand(tmpl, url string) []string {
+	if !strings.Contains(tmpl, "%s") && isExecutable(tmpl) {
+		return []string{tmpl, url}
+	}
+	argv := splitCommand(tmpl)
+	replaced := false
+	for i, v := range argv {
+		if strings.Contains(v, "%s") {
+			argv[i] = strings.ReplaceAll(v, "%s", url)
+			replaced = true
+		}
+	}
+	if !replaced {
+		argv = append(argv, url)
+	}
+	return argv
+}
+
+// splitCommand splits s into words separated by spaces. Single or double
+// quotes make a word with spaces, e.g. "'/opt/My Browser/browser' %s".
+// Backslashes are not escapes, so that Windows paths are kept as they are.
+func splitCommand(s string) []string {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// BrowserSink opens URLs with the first command that launches successfully.
+func BrowserSink(commands []string) URLSink {
+	return func(url string) error {
+		if len(commands) == 0 {
+			return errors.New("no browser is available. use --print or --copy")
+		}
+		var errs []string
+		for _, tmpl := range commands {
+			err := launch(expandBrowserCommand(tmpl, url))
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, err.Error())
+		}
+		return errors.Errorf("could not open the browser:\n  %s", strings.Join(errs, "\n  "))
+	}
+}
+
+func launch(argv []string) error {
+	if len(argv) == 0 {
+		return errors.New("empty browser command")
+	}
+	// The stderr is a file rather than a pipe, which browsers forked by
+	// launchers like xdg-open would inherit and keep open, so that Wait would
+	// not return until they exit.
+	stderr, err := ioutil.TempFile("", "gitb-browser")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(stderr.Name())
+	defer stderr.Close()
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			out, _ := ioutil.ReadFile(stderr.Name())
+			if msg := strings.TrimSpace(string(out)); msg != "" {
+				return errors.Errorf("%s: %v: %s", argv[0], err, msg)
+			}
+			return errors.Errorf("%s: %v", argv[0], err)
+		}
+		return nil
+	case <-time.After(launcherTimeout):
+		return cmd.Process.Release()
+	}
+}
+
+func defaultBrowserSink(cfg *Config) URLSink {
+	return BrowserSink(browserCommands(cfg.Get("browser"), os.Getenv, runtime.GOOS, detectWSL()))
+}
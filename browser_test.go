@@ -0,0 +1,170 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_browserCommands(t *testing.T) {
+	type args struct {
+		configured string
+		browser    string
+		goos       string
+		wsl        bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want []string
+	}{
+		{
+			args: args{configured: "firefox --new-tab %s", browser: "w3m", goos: "linux"},
+			want: []string{"firefox --new-tab %s"},
+		},
+		{
+			args: args{browser: "w3m:lynx -dump:", goos: "linux"},
+			want: []string{"w3m", "lynx -dump"},
+		},
+		{
+			args: args{goos: "linux"},
+			want: []string{"xdg-open"},
+		},
+		{
+			args: args{goos: "linux", wsl: true},
+			want: []string{"wslview", "xdg-open"},
+		},
+		{
+			args: args{goos: "darwin"},
+			want: []string{"open"},
+		},
+		{
+			args: args{goos: "windows"},
+			want: []string{"rundll32 url.dll,FileProtocolHandler"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string {
+				if key == "BROWSER" {
+					return tt.args.browser
+				}
+				return ""
+			}
+			if got := browserCommands(tt.args.configured, getenv, tt.args.goos, tt.args.wsl); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("browserCommands() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_expandBrowserCommand(t *testing.T) {
+	browser := filepath.Join(t.TempDir(), "Program Files", "browser.exe")
+	if err := os.MkdirAll(filepath.Dir(browser), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(browser, nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	type args struct {
+		tmpl string
+		url  string
+	}
+	tests := []struct {
+		name string
+		args args
+		want []string
+	}{
+		{
+			args: args{"xdg-open", "https://foo.backlog.com"},
+			want: []string{"xdg-open", "https://foo.backlog.com"},
+		},
+		{
+			args: args{"firefox --new-tab %s", "https://foo.backlog.com"},
+			want: []string{"firefox", "--new-tab", "https://foo.backlog.com"},
+		},
+		{
+			args: args{"open-url --url=%s --quiet", "https://foo.backlog.com"},
+			want: []string{"open-url", "--url=https://foo.backlog.com", "--quiet"},
+		},
+		{
+			name: "path with spaces",
+			args: args{browser, "https://foo.backlog.com"},
+			want: []string{browser, "https://foo.backlog.com"},
+		},
+		{
+			name: "quoted",
+			args: args{`'/opt/My Browser/browser' --new-tab "%s"`, "https://foo.backlog.com"},
+			want: []string{"/opt/My Browser/browser", "--new-tab", "https://foo.backlog.com"},
+		},
+		{
+			name: "windows",
+			args: args{`rundll32 url.dll,FileProtocolHandler`, "https://foo.backlog.com"},
+			want: []string{"rundll32", "url.dll,FileProtocolHandler", "https://foo.backlog.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expandBrowserCommand(tt.args.tmpl, tt.args.url); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expandBrowserCommand() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBrowserSink(t *testing.T) {
+	type args struct {
+		commands []string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			args:    args{[]string{"true"}},
+			wantErr: false,
+		},
+		{
+			args:    args{[]string{"gitb-no-such-browser", "false", "true"}},
+			wantErr: false,
+		},
+		{
+			args:    args{[]string{"gitb-no-such-browser", "false"}},
+			wantErr: true,
+		},
+		{
+			args:    args{nil},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := BrowserSink(tt.args.commands)("https://foo.backlog.com"); (err != nil) != tt.wantErr {
+				t.Errorf("BrowserSink() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_launch_forked(t *testing.T) {
+	// Launchers like xdg-open exit leaving the browser forked.
+	start := time.Now()
+	if err := launch([]string{"sh", "-c", "sleep 10 & exit 0"}); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d >= launcherTimeout {
+		t.Errorf("launch() took %v, want it to return when the launcher exits", d)
+	}
+}
+
+func Test_launch_error(t *testing.T) {
+	err := launch([]string{"sh", "-c", "echo no display >&2; exit 3"})
+	if err == nil || !strings.Contains(err.Error(), "no display") {
+		t.Errorf("launch() error = %v, want the stderr of the launcher", err)
+	}
+}
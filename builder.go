@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"path"
+	"strings"
 )
 
 func NewBacklogURLBuilder(domain, spaceKey string) *BacklogURLBuilder {
@@ -13,12 +14,20 @@ func NewBacklogURLBuilder(domain, spaceKey string) *BacklogURLBuilder {
 }
 
 type BacklogURLBuilder struct {
+	baseURL    string
 	domain     string
 	spaceKey   string
 	projectKey string
 	repoName   string
 }
 
+// SetBaseURL sets the web base URL of Backlog Enterprise or a custom domain.
+// When it is empty, the base URL is built from the space key and domain.
+func (b *BacklogURLBuilder) SetBaseURL(u string) *BacklogURLBuilder {
+	b.baseURL = strings.TrimSuffix(u, "/")
+	return b
+}
+
 func (b *BacklogURLBuilder) SetProjectKey(key string) *BacklogURLBuilder {
 	b.projectKey = key
 	return b
@@ -34,6 +43,9 @@ func (b *BacklogURLBuilder) Host() string {
 }
 
 func (b *BacklogURLBuilder) BaseURL() string {
+	if b.baseURL != "" {
+		return b.baseURL
+	}
 	return "https://" + b.Host()
 }
 
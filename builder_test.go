@@ -749,3 +749,37 @@ func TestBacklogURLBuilder_CommitURL(t *testing.T) {
 		})
 	}
 }
+
+func TestBacklogURLBuilder_SetBaseURL(t *testing.T) {
+	type args struct {
+		u string
+	}
+	tests := []struct {
+		name        string
+		args        args
+		wantBaseURL string
+		wantIssue   string
+	}{
+		{
+			args:        args{""},
+			wantBaseURL: "https://foo.backlog.com",
+			wantIssue:   "https://foo.backlog.com/view/BAR-1",
+		},
+		{
+			args:        args{"https://backlog.corp.example.com:8443/backlog/"},
+			wantBaseURL: "https://backlog.corp.example.com:8443/backlog",
+			wantIssue:   "https://backlog.corp.example.com:8443/backlog/view/BAR-1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBacklogURLBuilder("backlog.com", "foo").SetBaseURL(tt.args.u)
+			if got := b.BaseURL(); got != tt.wantBaseURL {
+				t.Errorf("BacklogURLBuilder.BaseURL() = %v, want %v", got, tt.wantBaseURL)
+			}
+			if got := b.IssueURL("BAR-1"); got != tt.wantIssue {
+				t.Errorf("BacklogURLBuilder.IssueURL() = %v, want %v", got, tt.wantIssue)
+			}
+		})
+	}
+}
@@ -48,15 +48,17 @@ func isWindows() bool {
 
 // https://github.com/Microsoft/WSL/issues/423#issuecomment-221627364
 func detectWSL() bool {
-	var detectedWSLContents string
-	b := make([]byte, 1024)
-	f, err := os.Open("/proc/version")
-	if err == nil {
-		f.Read(b)
-		f.Close()
-		detectedWSLContents = string(b)
+	b, err := os.ReadFile("/proc/version")
+	if err != nil {
+		return false
 	}
-	return strings.Contains(detectedWSLContents, "Microsoft")
+	return isWSLVersion(string(b))
+}
+
+// isWSLVersion reports whether the content of /proc/version is of WSL.
+// WSL 1 reports "Microsoft" and WSL 2 reports "microsoft".
+func isWSLVersion(s string) bool {
+	return strings.Contains(strings.ToLower(s), "microsoft")
 }
 
 // Spawn runs command with spawn(3)
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func Test_isWSLVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want bool
+	}{
+		{
+			s:    "Linux version 4.4.0-17134-Microsoft (Microsoft@Microsoft.com) (gcc version 5.4.0 (GCC) ) #48-Microsoft",
+			want: true,
+		},
+		{
+			s:    "Linux version 5.15.90.1-microsoft-standard-WSL2 (oe-user@oe-host) (x86_64-msft-linux-gcc (GCC) 9.3.0)",
+			want: true,
+		},
+		{
+			s:    "Linux version 6.1.0-13-amd64 (debian-kernel@lists.debian.org)",
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isWSLVersion(tt.s); got != tt.want {
+				t.Errorf("isWSLVersion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vvatanabe/gitb/internal/backlog"
+)
+
+// CommentOptions is the comment of `gitb issue comment` and `gitb pr comment`.
+type CommentOptions struct {
+	// Body is the comment. When it is empty, it is read by ReadComment once
+	// the issue or the pull request is found.
+	Body string
+	// Notify are the user IDs or the names of the users to notify.
+	Notify []string
+	// Attach are the paths of the files to attach. Only comments of issues
+	// support them, so `gitb pr comment` has no flag of them.
+	Attach []string
+}
+
+// readComment reads the comment on target when CommentOptions has no body.
+var readComment = ReadComment
+
+// CommentIssue adds the comment to the issue of key, found by findIssueKey
+// when it is empty, and returns the URL of the comment.
+func (b *BacklogRepository) CommentIssue(key string, opt *CommentOptions) (string, error) {
+	key, err := b.findIssueKey(key)
+	if err != nil {
+		return "", err
+	}
+	ctx := context.Background()
+	client, err := b.APIClient()
+	if err != nil {
+		return "", err
+	}
+	add, err := b.addCommentOptions(ctx, client, key, opt)
+	if err != nil {
+		return "", err
+	}
+	comment, err := client.AddIssueComment(ctx, key, add)
+	if err != nil {
+		return "", err
+	}
+	return b.urlBuilder().IssueURL(key) + "#comment-" + strconv.Itoa(comment.ID), nil
+}
+
+// CommentPullRequest adds the comment to the pull request of id, or of the
+// current branch when it is empty, and returns the URL of the pull request.
+func (b *BacklogRepository) CommentPullRequest(id string, opt *CommentOptions) (string, error) {
+	var err error
+	if id == "" {
+		if id, err = b.findPullRequestID(b.repo.HeadName()); err != nil {
+			return "", err
+		}
+	}
+	number, err := strconv.Atoi(strings.TrimPrefix(id, "#"))
+	if err != nil || number <= 0 {
+		return "", errors.Errorf("invalid pull request id: %s", id)
+	}
+	ctx := context.Background()
+	client, err := b.APIClient()
+	if err != nil {
+		return "", err
+	}
+	add, err := b.addCommentOptions(ctx, client, "pull request #"+strconv.Itoa(number), opt)
+	if err != nil {
+		return "", err
+	}
+	if _, err := client.AddPullRequestComment(ctx, b.projectKey, b.repoName, number, add); err != nil {
+		return "", err
+	}
+	return b.urlBuilder().PullRequestURL(strconv.Itoa(number)), nil
+}
+
+// addCommentOptions resolves the users to notify in the project, reads the
+// comment on target unless it is given, and uploads the files to attach. The
+// comment is read after the users and the files are found, so that a wrong
+// name or path does not lose the comment written.
+func (b *BacklogRepository) addCommentOptions(ctx context.Context, client *backlog.Client, target string, opt *CommentOptions) (*backlog.AddCommentOptions, error) {
+	add := &backlog.AddCommentOptions{}
+	if len(opt.Notify) > 0 {
+		users, err := client.GetProjectUsers(ctx, b.projectKey)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range opt.Notify {
+			u, err := findUser(users, v)
+			if err != nil {
+				return nil, err
+			}
+			add.NotifiedUserIDs = append(add.NotifiedUserIDs, u.ID)
+		}
+	}
+	for _, file := range opt.Attach {
+		fi, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		if fi.IsDir() {
+			return nil, errors.Errorf("could not attach %s: is a directory", file)
+		}
+	}
+	body, err := readComment(opt.Body, target)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(body) == "" {
+		return nil, errors.New("comment is empty")
+	}
+	add.Content = body
+	for _, file := range opt.Attach {
+		a, err := uploadFile(ctx, client, file)
+		if err != nil {
+			return nil, err
+		}
+		add.AttachmentIDs = append(add.AttachmentIDs, a.ID)
+	}
+	return add, nil
+}
+
+func uploadFile(ctx context.Context, client *backlog.Client, file string) (*backlog.Attachment, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	a, err := client.UploadAttachment(ctx, filepath.Base(file), f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not upload %s", file)
+	}
+	return a, nil
+}
+
+// ReadComment returns message when it is given. Otherwise it reads the
+// comment from the editor on a terminal. target is the issue or the pull
+// request shown in the editor. Stdin is read only by -F -, like the
+// description of `gitb issue create`.
+func ReadComment(message, target string) (string, error) {
+	if message != "" {
+		return message, nil
+	}
+	if !isTerminal(os.Stdin) {
+		return "", errors.New("comment is required. specify it by -m, or -F - to read stdin")
+	}
+	text, err := editText("COMMENT_EDITMSG.md", "\n"+commentHelp(target))
+	if err != nil {
+		return "", err
+	}
+	body := parseComment(text, target)
+	if body == "" {
+		return "", errors.New("aborting due to the empty comment")
+	}
+	return body, nil
+}
+
+func commentHelp(target string) string {
+	return fmt.Sprintf("# Write the comment on %s. These lines are removed.\n# An empty comment aborts.\n", target)
+}
+
+// parseComment removes the help of commentHelp from the text edited.
+func parseComment(text, target string) string {
+	help := make(map[string]bool)
+	for _, line := range strings.Split(commentHelp(target), "\n") {
+		if line != "" {
+			help[line] = true
+		}
+	}
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if !help[line] {
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/vvatanabe/gitb/internal/backlog"
+)
+
+func TestBacklogRepository_CommentIssue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "log.txt")
+	if err := ioutil.WriteFile(file, []byte("failed"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	responses := map[string]interface{}{
+		"GET /api/v2/projects/BAR/users": []*backlog.User{
+			{ID: 1, UserID: "alice", Name: "Alice"},
+			{ID: 2, UserID: "bob", Name: "Bob"},
+		},
+		"POST /api/v2/space/attachment":      &backlog.Attachment{ID: 7, Name: "log.txt"},
+		"POST /api/v2/issues/BAR-1/comments": &backlog.Comment{ID: 30},
+	}
+	tests := []struct {
+		name     string
+		key      string
+		opt      *CommentOptions
+		want     string
+		wantForm url.Values
+		wantErr  bool
+	}{
+		{
+			name: "current branch",
+			opt:  &CommentOptions{Body: "Fixed."},
+			want: "https://foo.backlog.com/view/BAR-1#comment-30",
+			wantForm: url.Values{
+				"content": {"Fixed."},
+			},
+		},
+		{
+			name: "notify and attach",
+			key:  "BAR-1",
+			opt:  &CommentOptions{Body: "See the log.", Notify: []string{"alice", "Bob"}, Attach: []string{file}},
+			want: "https://foo.backlog.com/view/BAR-1#comment-30",
+			wantForm: url.Values{
+				"content":          {"See the log."},
+				"notifiedUserId[]": {"1", "2"},
+				"attachmentId[]":   {"7"},
+			},
+		},
+		{
+			name:    "empty",
+			opt:     &CommentOptions{Body: " \n"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown user",
+			opt:     &CommentOptions{Body: "Fixed.", Notify: []string{"carol"}},
+			wantErr: true,
+		},
+		{
+			name:    "no file",
+			opt:     &CommentOptions{Body: "Fixed.", Attach: []string{filepath.Join(dir, "none.txt")}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forms := make(map[string]url.Values)
+			b := &BacklogRepository{
+				repo: &RepositoryMock{
+					HeadShortNameFunc: func() string {
+						return "feature/BAR-1"
+					},
+				},
+				api:        newAPIServer(t, responses, forms),
+				domain:     "backlog.com",
+				spaceKey:   "foo",
+				projectKey: "BAR",
+				repoName:   "baz",
+			}
+			got, err := b.CommentIssue(tt.key, tt.opt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BacklogRepository.CommentIssue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if _, ok := forms["POST /api/v2/issues/BAR-1/comments"]; ok {
+					t.Error("BacklogRepository.CommentIssue() commented on error")
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("BacklogRepository.CommentIssue() = %v, want %v", got, tt.want)
+			}
+			if form := forms["POST /api/v2/issues/BAR-1/comments"]; !reflect.DeepEqual(form, tt.wantForm) {
+				t.Errorf("BacklogRepository.CommentIssue() form = %v, want %v", form, tt.wantForm)
+			}
+		})
+	}
+}
+
+func TestBacklogRepository_CommentPullRequest(t *testing.T) {
+	responses := map[string]interface{}{
+		"POST /api/v2/projects/BAR/git/repositories/baz/pullRequests/3/comments": &backlog.Comment{ID: 30},
+	}
+	tests := []struct {
+		name    string
+		id      string
+		opt     *CommentOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "comment",
+			id:   "#3",
+			opt:  &CommentOptions{Body: "LGTM"},
+			want: "https://foo.backlog.com/git/BAR/baz/pullRequests/3",
+		},
+		{
+			name:    "invalid id",
+			id:      "abc",
+			opt:     &CommentOptions{Body: "LGTM"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forms := make(map[string]url.Values)
+			b := &BacklogRepository{
+				api:        newAPIServer(t, responses, forms),
+				domain:     "backlog.com",
+				spaceKey:   "foo",
+				projectKey: "BAR",
+				repoName:   "baz",
+			}
+			got, err := b.CommentPullRequest(tt.id, tt.opt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BacklogRepository.CommentPullRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("BacklogRepository.CommentPullRequest() = %v, want %v", got, tt.want)
+			}
+			if !tt.wantErr {
+				want := url.Values{"content": {tt.opt.Body}}
+				if form := forms["POST /api/v2/projects/BAR/git/repositories/baz/pullRequests/3/comments"]; !reflect.DeepEqual(form, want) {
+					t.Errorf("BacklogRepository.CommentPullRequest() form = %v, want %v", form, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBacklogRepository_Comment_read(t *testing.T) {
+	defer func(f func(message, target string) (string, error)) { readComment = f }(readComment)
+	var targets []string
+	readComment = func(message, target string) (string, error) {
+		targets = append(targets, target)
+		return "Read.", nil
+	}
+	newGitRepo(t)
+	responses := map[string]interface{}{
+		"GET /api/v2/projects/BAR/users":                                         []*backlog.User{{ID: 1, UserID: "alice", Name: "Alice"}},
+		"POST /api/v2/issues/BAR-1/comments":                                     &backlog.Comment{ID: 30},
+		"POST /api/v2/projects/BAR/git/repositories/baz/pullRequests/3/comments": &backlog.Comment{ID: 31},
+	}
+	tests := []struct {
+		name        string
+		branch      string
+		comment     func(b *BacklogRepository) error
+		wantTargets []string
+		wantErr     bool
+	}{
+		{
+			name:   "issue",
+			branch: "feature/BAR-1",
+			comment: func(b *BacklogRepository) error {
+				_, err := b.CommentIssue("", &CommentOptions{})
+				return err
+			},
+			wantTargets: []string{"BAR-1"},
+		},
+		{
+			name:   "issue not found",
+			branch: "master",
+			comment: func(b *BacklogRepository) error {
+				_, err := b.CommentIssue("", &CommentOptions{})
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name: "pull request",
+			comment: func(b *BacklogRepository) error {
+				_, err := b.CommentPullRequest("3", &CommentOptions{})
+				return err
+			},
+			wantTargets: []string{"pull request #3"},
+		},
+		{
+			name: "invalid pull request",
+			comment: func(b *BacklogRepository) error {
+				_, err := b.CommentPullRequest("abc", &CommentOptions{})
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown user",
+			comment: func(b *BacklogRepository) error {
+				_, err := b.CommentPullRequest("3", &CommentOptions{Notify: []string{"carol"}})
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name:   "no file",
+			branch: "feature/BAR-1",
+			comment: func(b *BacklogRepository) error {
+				_, err := b.CommentIssue("", &CommentOptions{Attach: []string{"none.txt"}})
+				return err
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets = nil
+			var calls [][]string
+			b := &BacklogRepository{
+				repo: &RepositoryMock{
+					HeadShortNameFunc: func() string {
+						return tt.branch
+					},
+					// No commit messages with the issue key.
+					GitFunc: fakeGitCommand(&calls, nil),
+				},
+				api:        newAPIServer(t, responses, nil),
+				domain:     "backlog.com",
+				spaceKey:   "foo",
+				projectKey: "BAR",
+				repoName:   "baz",
+			}
+			if err := tt.comment(b); (err != nil) != tt.wantErr {
+				t.Fatalf("comment error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(targets, tt.wantTargets) {
+				t.Errorf("comment read the comment on %v, want %v", targets, tt.wantTargets)
+			}
+		})
+	}
+}
+
+func Test_parseComment(t *testing.T) {
+	text := "# Heading\n\nFixed.\n" + commentHelp("BAR-1")
+	if got, want := parseComment(text, "BAR-1"), "# Heading\n\nFixed."; got != want {
+		t.Errorf("parseComment() = %q, want %q", got, want)
+	}
+	if got := parseComment("\n"+commentHelp("BAR-1"), "BAR-1"); got != "" {
+		t.Errorf("parseComment() = %q, want empty", got)
+	}
+}
+
+func TestReadComment(t *testing.T) {
+	got, err := ReadComment("Fixed.", "BAR-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Fixed." {
+		t.Errorf("ReadComment() = %q, want %q", got, "Fixed.")
+	}
+
+	// Piped stdin is not read without -F -.
+	f, err := ioutil.TempFile("", "gitb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := f.WriteString("Fixed.\n"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer func(stdin *os.File) { os.Stdin = stdin }(os.Stdin)
+	os.Stdin = f
+	if got, err := ReadComment("", "BAR-1"); err == nil {
+		t.Errorf("ReadComment() = %q, want an error without the comment", got)
+	}
+}
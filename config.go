@@ -0,0 +1,312 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+)
+
+// Config is the layered configuration of gitb.
+//
+// Values are looked up in the following order, and the first one found wins:
+//
+//  1. GITB_* environment variables (e.g. GITB_PR_BASE for "pr.base")
+//  2. "gitb.*" keys of git config (repository, global and system)
+//  3. .gitb.toml in the root directory of the repository
+//  4. the user config file (~/.config/gitb/config, in git config format)
+//  5. built-in defaults
+//
+// Command line flags take precedence over all of them.
+type Config struct {
+	layers []*configLayer
+}
+
+type configLayer struct {
+	source string
+	values map[string]string
+}
+
+const (
+	configEnvPrefix = "GITB_"
+	repoConfigFile  = ".gitb.toml"
+)
+
+type configKey struct {
+	name         string
+	defaultValue string
+	usage        string
+}
+
+// configKeys are the keys known to gitb. Host keys such as
+// "<host>.baseurl" are accepted in addition to them.
+var configKeys = []configKey{
+	{"remote", "", "Remote of Backlog's repository"},
+	{"space", "", "Space key used instead of the one detected from the remote"},
+	{"browser", "", "Command to open URLs. %s is replaced with the URL"},
+	{"pr.base", "", "Default base branch of pull requests"},
+	{"pr.state", "open", "Default state of the pull request list"},
+	{"pr.mergepattern", "", "Regexp of merge commit subjects for gitb pr blame. Its first group is the PR ID"},
+	{"issue.state", "not_closed", "Default state of the issue list"},
+	{"issue.type", "", "Default issue type of gitb issue create. The first type of the project when empty"},
+	{"issue.priority", "", "Default priority of gitb issue create. Normal when empty"},
+	{"issue.branch", "feature/{key}-{slug}", "Branch name of gitb issue start. {key} is the issue key, and {slug} is made of the summary"},
+	{"oauth.clientid", "", "Client ID of the OAuth 2.0 application for gitb auth login --oauth"},
+	{"oauth.clientsecret", "", "Client secret of the OAuth 2.0 application"},
+	{"oauth.redirecturi", "http://localhost:8765/callback", "Loopback redirect URI registered for the OAuth 2.0 application"},
+}
+
+func LoadConfig(dir string) (*Config, error) {
+	c := &Config{}
+	c.layers = append(c.layers, envConfigLayer(os.Environ()))
+	out, _ := exec.Command("git", "-C", dir, "config", "--get-regexp", `^gitb\.`).Output()
+	c.layers = append(c.layers, &configLayer{
+		source: "git config",
+		values: parseGitConfig(out),
+	})
+	if out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output(); err == nil {
+		file := filepath.Join(strings.TrimSpace(string(out)), repoConfigFile)
+		values, err := readTOMLConfig(file)
+		if err != nil {
+			return nil, err
+		}
+		for key := range values {
+			if !isRepoConfigKey(key) {
+				fmt.Fprintf(os.Stderr, "gitb: ignored %s in %s. only pr.*, issue.* and space can be set in it\n", key, file)
+				delete(values, key)
+			}
+		}
+		c.layers = append(c.layers, &configLayer{
+			source: file,
+			values: values,
+		})
+	}
+	if file := userConfigFile(); file != "" {
+		out, _ := exec.Command("git", "config", "-f", file, "--get-regexp", `^gitb\.`).Output()
+		c.layers = append(c.layers, &configLayer{
+			source: file,
+			values: parseGitConfig(out),
+		})
+	}
+	c.layers = append(c.layers, defaultConfigLayer())
+	return c, nil
+}
+
+// Get returns the value of key, or empty string when key is not set.
+// It is safe to call on a nil Config.
+func (c *Config) Get(key string) string {
+	v, _, _ := c.Lookup(key)
+	return v
+}
+
+// Lookup returns the value of key and the source it was read from.
+func (c *Config) Lookup(key string) (value, source string, ok bool) {
+	if c == nil {
+		return "", "", false
+	}
+	for _, l := range c.layers {
+		if v, ok := l.values[key]; ok {
+			return v, l.source, true
+		}
+	}
+	return "", "", false
+}
+
+// ConfigEntry is a key and its effective value.
+type ConfigEntry struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// List returns the effective value of every key set in any layer, sorted by key.
+func (c *Config) List() []ConfigEntry {
+	if c == nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var entries []ConfigEntry
+	for _, l := range c.layers {
+		for k, v := range l.values {
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			entries = append(entries, ConfigEntry{Key: k, Value: v, Source: l.source})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	return entries
+}
+
+// Hosts returns the host mapping merged over all layers.
+func (c *Config) Hosts() Hosts {
+	hosts := make(Hosts)
+	if c == nil {
+		return hosts
+	}
+	for i := len(c.layers) - 1; i >= 0; i-- {
+		hosts.set(c.layers[i].values)
+	}
+	return hosts
+}
+
+// SetConfig writes key to git config of the repository in dir, or to the
+// user config file when global is true.
+func SetConfig(dir, key, value string, global bool) error {
+	if !isValidConfigKey(key) {
+		return errors.Errorf("unknown key %s. see `gitb config list --all`", key)
+	}
+	args := []string{"-C", dir, "config"}
+	if global {
+		file := userConfigFile()
+		if file == "" {
+			return errors.New("could not find the user config directory")
+		}
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return err
+		}
+		args = append(args, "-f", file)
+	}
+	args = append(args, "gitb."+key, value)
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func isValidConfigKey(key string) bool {
+	for _, k := range configKeys {
+		if k.name == key {
+			return true
+		}
+	}
+	i := strings.LastIndex(key, ".")
+	if i < 1 {
+		return false
+	}
+	switch key[i+1:] {
+	case hostBaseURLKey, hostSpaceKey, hostPathPrefixKey:
+		return true
+	}
+	return false
+}
+
+// isRepoConfigKey reports whether key can be set in .gitb.toml. The file
+// comes with the repository cloned, so it must not set the keys which run
+// commands or send the credentials elsewhere, e.g. browser and the host keys.
+func isRepoConfigKey(key string) bool {
+	if key != "space" && !strings.HasPrefix(key, "pr.") && !strings.HasPrefix(key, "issue.") {
+		return false
+	}
+	for _, k := range configKeys {
+		if k.name == key {
+			return true
+		}
+	}
+	return false
+}
+
+func envConfigLayer(environ []string) *configLayer {
+	values := make(map[string]string)
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, configEnvPrefix) {
+			continue
+		}
+		keyAndValue := strings.SplitN(strings.TrimPrefix(kv, configEnvPrefix), "=", 2)
+		if len(keyAndValue) != 2 || keyAndValue[0] == "" {
+			continue
+		}
+		key := strings.ToLower(strings.ReplaceAll(keyAndValue[0], "_", "."))
+		values[key] = keyAndValue[1]
+	}
+	return &configLayer{
+		source: "environment",
+		values: values,
+	}
+}
+
+func defaultConfigLayer() *configLayer {
+	values := make(map[string]string)
+	for _, k := range configKeys {
+		if k.defaultValue != "" {
+			values[k.name] = k.defaultValue
+		}
+	}
+	return &configLayer{
+		source: "default",
+		values: values,
+	}
+}
+
+// parseGitConfig reads the output of `git config --get-regexp ^gitb\.`.
+func parseGitConfig(b []byte) map[string]string {
+	values := make(map[string]string)
+	for _, line := range strings.Split(string(b), "\n") {
+		keyAndValue := strings.SplitN(line, " ", 2)
+		if !strings.HasPrefix(keyAndValue[0], "gitb.") {
+			continue
+		}
+		var value string
+		if len(keyAndValue) == 2 {
+			value = keyAndValue[1]
+		}
+		values[strings.TrimPrefix(keyAndValue[0], "gitb.")] = value
+	}
+	return values
+}
+
+func readTOMLConfig(file string) (map[string]string, error) {
+	b, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	values, err := parseTOMLConfig(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse %s", file)
+	}
+	return values, nil
+}
+
+// parseTOMLConfig flattens tables into keys joined with dots, like git config.
+// Table names are kept as they are, and the last part of a key is lower-cased.
+func parseTOMLConfig(b []byte) (map[string]string, error) {
+	var tree map[string]interface{}
+	if _, err := toml.Decode(string(b), &tree); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	flattenTOML("", tree, values)
+	return values, nil
+}
+
+func flattenTOML(prefix string, tree map[string]interface{}, values map[string]string) {
+	for k, v := range tree {
+		if t, ok := v.(map[string]interface{}); ok {
+			flattenTOML(prefix+k+".", t, values)
+			continue
+		}
+		values[prefix+strings.ToLower(k)] = fmt.Sprint(v)
+	}
+}
+
+func userConfigFile() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "gitb", "config")
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfig_Lookup(t *testing.T) {
+	c := &Config{layers: []*configLayer{
+		{source: "environment", values: map[string]string{"pr.base": "develop"}},
+		{source: "git config", values: map[string]string{"pr.base": "main", "remote": "backlog"}},
+		defaultConfigLayer(),
+	}}
+	type args struct {
+		key string
+	}
+	tests := []struct {
+		name       string
+		args       args
+		wantValue  string
+		wantSource string
+		wantOk     bool
+	}{
+		{
+			args:       args{"pr.base"},
+			wantValue:  "develop",
+			wantSource: "environment",
+			wantOk:     true,
+		},
+		{
+			args:       args{"remote"},
+			wantValue:  "backlog",
+			wantSource: "git config",
+			wantOk:     true,
+		},
+		{
+			args:       args{"pr.state"},
+			wantValue:  "open",
+			wantSource: "default",
+			wantOk:     true,
+		},
+		{
+			args:   args{"browser"},
+			wantOk: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotValue, gotSource, gotOk := c.Lookup(tt.args.key)
+			if gotValue != tt.wantValue {
+				t.Errorf("Config.Lookup() gotValue = %v, want %v", gotValue, tt.wantValue)
+			}
+			if gotSource != tt.wantSource {
+				t.Errorf("Config.Lookup() gotSource = %v, want %v", gotSource, tt.wantSource)
+			}
+			if gotOk != tt.wantOk {
+				t.Errorf("Config.Lookup() gotOk = %v, want %v", gotOk, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestConfig_Get_nil(t *testing.T) {
+	var c *Config
+	if got := c.Get("pr.state"); got != "" {
+		t.Errorf("Config.Get() = %v, want empty", got)
+	}
+}
+
+func TestConfig_List(t *testing.T) {
+	c := &Config{layers: []*configLayer{
+		{source: "environment", values: map[string]string{"pr.base": "develop"}},
+		{source: "git config", values: map[string]string{"pr.base": "main", "remote": "backlog"}},
+	}}
+	want := []ConfigEntry{
+		{Key: "pr.base", Value: "develop", Source: "environment"},
+		{Key: "remote", Value: "backlog", Source: "git config"},
+	}
+	if got := c.List(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Config.List() = %v, want %v", got, want)
+	}
+}
+
+func Test_envConfigLayer(t *testing.T) {
+	type args struct {
+		environ []string
+	}
+	tests := []struct {
+		name string
+		args args
+		want map[string]string
+	}{
+		{
+			args: args{[]string{
+				"HOME=/home/foo",
+				"GITB_PR_BASE=develop",
+				"GITB_ISSUE_STATE=all",
+				"GITB_=foo",
+			}},
+			want: map[string]string{
+				"pr.base":     "develop",
+				"issue.state": "all",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := envConfigLayer(tt.args.environ); !reflect.DeepEqual(got.values, tt.want) {
+				t.Errorf("envConfigLayer() = %v, want %v", got.values, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseGitConfig(t *testing.T) {
+	type args struct {
+		b []byte
+	}
+	tests := []struct {
+		name string
+		args args
+		want map[string]string
+	}{
+		{
+			args: args{[]byte(`gitb.remote backlog
+gitb.pr.base develop
+gitb.git.corp.example.com.baseurl https://backlog.corp.example.com
+gitb.empty
+`)},
+			want: map[string]string{
+				"remote":                       "backlog",
+				"pr.base":                      "develop",
+				"git.corp.example.com.baseurl": "https://backlog.corp.example.com",
+				"empty":                        "",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseGitConfig(tt.args.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseGitConfig() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseTOMLConfig(t *testing.T) {
+	type args struct {
+		b []byte
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			args: args{[]byte(`remote = "backlog"
+
+[pr]
+base = "develop"
+
+["git.corp.example.com"]
+baseURL = "https://backlog.corp.example.com"
+`)},
+			want: map[string]string{
+				"remote":                       "backlog",
+				"pr.base":                      "develop",
+				"git.corp.example.com.baseurl": "https://backlog.corp.example.com",
+			},
+		},
+		{
+			args:    args{[]byte(`remote = `)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTOMLConfig(tt.args.b)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseTOMLConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTOMLConfig() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_isValidConfigKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"pr.base", true},
+		{"git.corp.example.com.baseurl", true},
+		{"baseurl", false},
+		{"pr.unknown", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := isValidConfigKey(tt.key); got != tt.want {
+				t.Errorf("isValidConfigKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_isRepoConfigKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"pr.base", true},
+		{"issue.branch", true},
+		{"space", true},
+		{"pr.unknown", false},
+		{"browser", false},
+		{"remote", false},
+		{"oauth.clientid", false},
+		{"git.corp.example.com.baseurl", false},
+		{"issue.example.com.baseurl", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := isRepoConfigKey(tt.key); got != tt.want {
+				t.Errorf("isRepoConfigKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// editText lets the user edit text in the editor of git, which is
+// core.editor, $VISUAL or $EDITOR, and returns the result. name is the name
+// of the temporary file, whose extension chooses the syntax of the editor.
+func editText(name, text string) (string, error) {
+	out, err := exec.Command("git", "var", "GIT_EDITOR").Output()
+	if err != nil {
+		return "", errors.Wrap(err, "could not find the editor")
+	}
+	editor := strings.TrimSpace(string(out))
+	dir, err := ioutil.TempDir("", "gitb")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(file, []byte(text), 0600); err != nil {
+		return "", err
+	}
+	// The editor may have arguments, so it is run by the shell like git does.
+	cmd := exec.Command("sh", "-c", editor+` "$@"`, editor, file)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", errors.Wrapf(err, "the editor %s failed", editor)
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ListFormat is how lists are printed: a table by default, or JSON or a Go
+// template for scripting.
+type ListFormat struct {
+	JSON     bool
+	Template string
+	Color    bool
+}
+
+// printList prints items, a slice, as JSON or with the template of f applied
+// to each item. It returns false when the table should be printed instead.
+func (f *ListFormat) printList(w io.Writer, items interface{}) (bool, error) {
+	switch {
+	case f.JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return true, enc.Encode(items)
+	case f.Template != "":
+		tmpl, err := template.New("format").Funcs(templateFuncs).Parse(f.Template + "\n")
+		if err != nil {
+			return true, err
+		}
+		v := reflect.ValueOf(items)
+		for i := 0; i < v.Len(); i++ {
+			if err := tmpl.Execute(w, v.Index(i).Interface()); err != nil {
+				return true, err
+			}
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+var templateFuncs = template.FuncMap{
+	"date": func(t time.Time) string {
+		return t.Local().Format("2006-01-02")
+	},
+}
+
+const (
+	colorRed     = 31
+	colorGreen   = 32
+	colorYellow  = 33
+	colorBlue    = 34
+	colorMagenta = 35
+	colorCyan    = 36
+	colorGray    = 90
+)
+
+// colorize wraps s with the SGR sequence of color when enabled.
+func colorize(s string, color int, enabled bool) string {
+	if !enabled {
+		return s
+	}
+	return fmt.Sprintf("\x1b[%dm%s\x1b[0m", color, s)
+}
+
+// useColor reports whether colors should be written to f.
+// See https://no-color.org/ for NO_COLOR.
+func useColor(f *os.File) bool {
+	return isTerminal(f) && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb"
+}
+
+// hyperlink makes s a link to u with the OSC 8 sequence, which terminals
+// without its support ignore.
+func hyperlink(s, u string) string {
+	return fmt.Sprintf("\x1b]8;;%s\x1b\\%s\x1b]8;;\x1b\\", u, s)
+}
+
+// displayWidth is the number of columns s takes on terminals, counting East
+// Asian wide characters as two.
+func displayWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		w += runeWidth(r)
+	}
+	return w
+}
+
+// escapeSequencePattern matches the SGR sequences of colorize and the OSC 8
+// sequences of hyperlink, which take no columns.
+var escapeSequencePattern = regexp.MustCompile("\x1b\\[[0-9;]*m|\x1b\\]8;;[^\x1b]*\x1b\\\\")
+
+// textWidth is the displayWidth of s without escape sequences.
+func textWidth(s string) int {
+	return displayWidth(escapeSequencePattern.ReplaceAllString(s, ""))
+}
+
+// printTable prints rows with their cells aligned in columns, which are
+// separated by two spaces. Unlike text/tabwriter, it counts the columns wide
+// characters take on terminals, and ignores escape sequences.
+func printTable(w io.Writer, rows [][]string) error {
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], textWidth(cell))
+		}
+	}
+	for _, row := range rows {
+		var sb strings.Builder
+		for i, cell := range row {
+			sb.WriteString(cell)
+			if i < len(row)-1 {
+				sb.WriteString(strings.Repeat(" ", widths[i]-textWidth(cell)+2))
+			}
+		}
+		sb.WriteString("\n")
+		if _, err := io.WriteString(w, sb.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// truncate shortens s to width columns, ending it with "…" when cut.
+func truncate(s string, width int) string {
+	if displayWidth(s) <= width {
+		return s
+	}
+	w := 0
+	for i, r := range s {
+		if w+runeWidth(r) > width-1 {
+			return s[:i] + "…"
+		}
+		w += runeWidth(r)
+	}
+	return s
+}
+
+func runeWidth(r rune) int {
+	switch {
+	case r >= 0x1100 && r <= 0x115f, // Hangul Jamo
+		r >= 0x2e80 && r <= 0xa4cf && r != 0x303f, // CJK to Yi
+		r >= 0xac00 && r <= 0xd7a3,                // Hangul Syllables
+		r >= 0xf900 && r <= 0xfaff,                // CJK Compatibility Ideographs
+		r >= 0xfe30 && r <= 0xfe4f,                // CJK Compatibility Forms
+		r >= 0xff00 && r <= 0xff60,                // Fullwidth Forms
+		r >= 0xffe0 && r <= 0xffe6,
+		r >= 0x20000 && r <= 0x3fffd:
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_truncate(t *testing.T) {
+	tests := []struct {
+		name  string
+		s     string
+		width int
+		want  string
+	}{
+		{name: "short", s: "abc", width: 3, want: "abc"},
+		{name: "cut", s: "abcdef", width: 4, want: "abc…"},
+		{name: "wide", s: "課題の件名", width: 6, want: "課題…"},
+		{name: "wide fits", s: "課題", width: 4, want: "課題"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncate(tt.s, tt.width); got != tt.want {
+				t.Errorf("truncate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_printTable(t *testing.T) {
+	rows := [][]string{
+		{colorize("BAR-1", colorCyan, true), "課題", "Alice"},
+		{hyperlink("BAR-12", "https://foo.backlog.com/view/BAR-12"), "Issue", "-"},
+	}
+	want := "\x1b[36mBAR-1\x1b[0m   課題   Alice\n" +
+		"\x1b]8;;https://foo.backlog.com/view/BAR-12\x1b\\BAR-12\x1b]8;;\x1b\\  Issue  -\n"
+	var buf bytes.Buffer
+	if err := printTable(&buf, rows); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("printTable() = %q, want %q", got, want)
+	}
+}
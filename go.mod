@@ -3,7 +3,9 @@ module github.com/vvatanabe/gitb
 go 1.16
 
 require (
+	github.com/BurntSushi/toml v1.2.1
 	github.com/pkg/errors v0.8.1
 	github.com/urfave/cli v1.22.2
+	golang.org/x/crypto v0.0.0-20190701094942-4def268fd1a4
 	gopkg.in/src-d/go-git.v4 v4.13.1
 )
@@ -0,0 +1,55 @@
+package main
+
+import "strings"
+
+// HostConfig describes where the Backlog of a remote host lives on the web.
+// It is used for Backlog Enterprise and custom domains, whose URLs cannot be
+// derived from the remote host.
+type HostConfig struct {
+	// BaseURL is the web base URL. e.g. https://backlog.corp.example.com:8443/backlog
+	BaseURL string
+	// SpaceKey overrides the space key extracted from the remote host.
+	SpaceKey string
+	// PathPrefix is trimmed from the remote path before the project key.
+	PathPrefix string
+}
+
+// Hosts maps a remote host name to its HostConfig.
+//
+// It is read from the "<host>.baseurl", "<host>.space" and "<host>.pathprefix"
+// keys of Config. e.g. gitb.git.corp.example.com.baseurl in git config.
+type Hosts map[string]HostConfig
+
+const (
+	hostBaseURLKey    = "baseurl"
+	hostSpaceKey      = "space"
+	hostPathPrefixKey = "pathprefix"
+)
+
+// set overrides h with the host keys found in values.
+func (h Hosts) set(values map[string]string) {
+	for key, value := range values {
+		i := strings.LastIndex(key, ".")
+		if i < 1 {
+			continue
+		}
+		host := key[:i]
+		c := h[host]
+		switch key[i+1:] {
+		case hostBaseURLKey:
+			c.BaseURL = strings.TrimSuffix(value, "/")
+		case hostSpaceKey:
+			c.SpaceKey = value
+		case hostPathPrefixKey:
+			c.PathPrefix = "/" + strings.Trim(value, "/")
+		default:
+			continue
+		}
+		h[host] = c
+	}
+}
+
+func (h Hosts) Lookup(host string) (HostConfig, bool) {
+	c, ok := h[host]
+	return c, ok
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHosts_set(t *testing.T) {
+	type args struct {
+		values map[string]string
+	}
+	tests := []struct {
+		name  string
+		hosts Hosts
+		args  args
+		want  Hosts
+	}{
+		{
+			hosts: Hosts{
+				"backlog.corp.example.com": HostConfig{
+					BaseURL:  "https://backlog.corp.example.com",
+					SpaceKey: "foo",
+				},
+			},
+			args: args{map[string]string{
+				"remote":                           "backlog",
+				"pr.base":                          "develop",
+				"backlog.corp.example.com.baseurl": "https://backlog.corp.example.com:8443/backlog/",
+				"git.corp.example.com.pathprefix":  "backlog/",
+				"git.corp.example.com.unknown":     "foo",
+			}},
+			want: Hosts{
+				"backlog.corp.example.com": HostConfig{
+					BaseURL:  "https://backlog.corp.example.com:8443/backlog",
+					SpaceKey: "foo",
+				},
+				"git.corp.example.com": HostConfig{
+					PathPrefix: "/backlog",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.hosts.set(tt.args.values)
+			if !reflect.DeepEqual(tt.hosts, tt.want) {
+				t.Errorf("Hosts.set() = %v, want %v", tt.hosts, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+// Package backlog is a client of Backlog API v2.
+//
+// See https://developer.nulab.com/docs/backlog/ for the API.
+package backlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	apiPath = "/api/v2"
+
+	defaultTimeout    = 30 * time.Second
+	defaultMaxRetries = 3
+	// maxRetryWait caps the wait for rate limits, which reset every minute.
+	maxRetryWait = 60 * time.Second
+	// maxCount is the maximum count of items per page.
+	maxCount = 100
+)
+
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		timeout:    defaultTimeout,
+		maxRetries: defaultMaxRetries,
+	}
+}
+
+// Client calls Backlog API v2 of a space.
+type Client struct {
+	baseURL     string
+	apiKey      string
+	accessToken string
+	httpClient  *http.Client
+	timeout     time.Duration
+	maxRetries  int
+}
+
+// SetAPIKey authenticates requests with the API key.
+func (c *Client) SetAPIKey(key string) *Client {
+	c.apiKey = key
+	return c
+}
+
+// SetAccessToken authenticates requests with the OAuth 2.0 access token.
+// It takes precedence over the API key.
+func (c *Client) SetAccessToken(token string) *Client {
+	c.accessToken = token
+	return c
+}
+
+func (c *Client) SetHTTPClient(hc *http.Client) *Client {
+	c.httpClient = hc
+	return c
+}
+
+// SetTimeout sets the timeout of each call, including retries.
+// Zero means no timeout other than the one of the context.
+func (c *Client) SetTimeout(d time.Duration) *Client {
+	c.timeout = d
+	return c
+}
+
+// SetMaxRetries sets how many times a rate limited request is retried.
+func (c *Client) SetMaxRetries(n int) *Client {
+	c.maxRetries = n
+	return c
+}
+
+// BaseURL returns the web base URL of the space.
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// ErrorResponse is returned when the API responds with a non 2xx status.
+type ErrorResponse struct {
+	StatusCode int
+	Errors     []Error `json:"errors"`
+}
+
+// Error is an error reported by the API.
+type Error struct {
+	Message  string `json:"message"`
+	Code     int    `json:"code"`
+	MoreInfo string `json:"moreInfo"`
+}
+
+func (e *ErrorResponse) Error() string {
+	var messages []string
+	for _, v := range e.Errors {
+		messages = append(messages, v.Message)
+	}
+	if len(messages) == 0 {
+		return fmt.Sprintf("backlog: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("backlog: %d %s", e.StatusCode, strings.Join(messages, ", "))
+}
+
+// IsNotFound reports whether err is a 404 response of the API.
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsUnauthorized reports whether err is a 401 response of the API.
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized)
+}
+
+func hasStatus(err error, code int) bool {
+	e, ok := errors.Cause(err).(*ErrorResponse)
+	return ok && e.StatusCode == code
+}
+
+// request is an API request. Body is kept as bytes so that it can be resent.
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        []byte
+	contentType string
+}
+
+func (c *Client) get(ctx context.Context, path string, query url.Values, v interface{}) error {
+	return c.do(ctx, &request{method: http.MethodGet, path: path, query: query}, v)
+}
+
+func (c *Client) send(ctx context.Context, method, path string, form url.Values, v interface{}) error {
+	return c.do(ctx, &request{
+		method:      method,
+		path:        path,
+		body:        []byte(form.Encode()),
+		contentType: "application/x-www-form-urlencoded",
+	}, v)
+}
+
+// upload sends the content of r as the file of a multipart form.
+func (c *Client) upload(ctx context.Context, path, name string, r io.Reader, v interface{}) error {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("file", name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.do(ctx, &request{
+		method:      http.MethodPost,
+		path:        path,
+		body:        body.Bytes(),
+		contentType: w.FormDataContentType(),
+	}, v)
+}
+
+// do sends r, retrying it while it is rate limited. The timeout of the client
+// applies to each attempt, as the wait for a rate limit may be longer.
+func (c *Client) do(ctx context.Context, r *request, v interface{}) error {
+	for attempt := 0; ; attempt++ {
+		wait, retry, err := c.attempt(ctx, r, v, attempt)
+		if !retry {
+			return err
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// attempt sends r once. When it is rate limited and can be retried, it
+// returns retry with the time to wait. It cannot be retried when the wait
+// exceeds the deadline of ctx, and the rate limit error is returned instead.
+func (c *Client) attempt(ctx context.Context, r *request, v interface{}, n int) (wait time.Duration, retry bool, err error) {
+	actx := ctx
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		actx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	resp, err := c.roundTrip(actx, r)
+	if err != nil {
+		return 0, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests && n < c.maxRetries {
+		now := time.Now()
+		wait = retryWait(resp.Header, n, now)
+		if deadline, ok := ctx.Deadline(); !ok || now.Add(wait).Before(deadline) {
+			return wait, true, nil
+		}
+	}
+	return 0, false, decodeResponse(resp, v)
+}
+
+func (c *Client) roundTrip(ctx context.Context, r *request) (*http.Response, error) {
+	query := url.Values{}
+	for k, v := range r.query {
+		query[k] = v
+	}
+	if c.accessToken == "" && c.apiKey != "" {
+		query.Set("apiKey", c.apiKey)
+	}
+	u := c.baseURL + apiPath + r.path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if r.contentType != "" {
+		req.Header.Set("Content-Type", r.contentType)
+	}
+	if c.accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	}
+	req.Header.Set("Accept", "application/json")
+	return c.httpClient.Do(req)
+}
+
+func decodeResponse(resp *http.Response, v interface{}) error {
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		e := &ErrorResponse{StatusCode: resp.StatusCode}
+		_ = json.Unmarshal(b, e)
+		return e
+	}
+	if v == nil || len(b) == 0 {
+		return nil
+	}
+	return errors.Wrap(json.Unmarshal(b, v), "could not decode the response of Backlog API")
+}
+
+// retryWait returns how long to wait before retrying a rate limited request.
+// It follows Retry-After or X-RateLimit-Reset, and backs off exponentially
+// when neither is given.
+func retryWait(h http.Header, attempt int, now time.Time) time.Duration {
+	wait := time.Duration(1<<uint(attempt)) * time.Second
+	if v, err := strconv.Atoi(h.Get("Retry-After")); err == nil {
+		wait = time.Duration(v) * time.Second
+	} else if v, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		wait = time.Unix(v, 0).Sub(now)
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > maxRetryWait {
+		wait = maxRetryWait
+	}
+	return wait
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// paginate calls fetch with increasing offsets until a page is not full or
+// limit items are fetched. limit <= 0 means no limit. fetch returns the
+// number of items in the page.
+func paginate(limit int, fetch func(offset, count int) (int, error)) error {
+	for offset := 0; limit <= 0 || offset < limit; {
+		count := maxCount
+		if limit > 0 && limit-offset < count {
+			count = limit - offset
+		}
+		n, err := fetch(offset, count)
+		if err != nil {
+			return err
+		}
+		offset += n
+		if n < count {
+			return nil
+		}
+	}
+	return nil
+}
+
+func addInts(v url.Values, key string, ids []int) {
+	for _, id := range ids {
+		v.Add(key, strconv.Itoa(id))
+	}
+}
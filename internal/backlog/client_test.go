@@ -0,0 +1,294 @@
+package backlog
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return NewClient(server.URL).SetAPIKey("secret").SetHTTPClient(server.Client())
+}
+
+func TestClient_GetMyself(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		handler http.HandlerFunc
+		want    *User
+		wantErr bool
+	}{
+		{
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v2/users/myself" || r.URL.Query().Get("apiKey") != "secret" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				fmt.Fprint(w, `{"id":1,"userId":"admin","name":"admin","roleType":1}`)
+			},
+			want: &User{ID: 1, UserID: "admin", Name: "admin", RoleType: 1},
+		},
+		{
+			token: "token",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer token" || r.URL.Query().Get("apiKey") != "" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				fmt.Fprint(w, `{"id":1,"userId":"admin","name":"admin","roleType":1}`)
+			},
+			want: &User{ID: 1, UserID: "admin", Name: "admin", RoleType: 1},
+		},
+		{
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprint(w, `{"errors":[{"message":"Authentication failure.","code":11,"moreInfo":""}]}`)
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, tt.handler).SetAccessToken(tt.token)
+			got, err := c.GetMyself(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Client.GetMyself() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Client.GetMyself() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"errors":[{"message":"No such issue.","code":6,"moreInfo":""}]}`)
+	})
+	_, err := c.GetIssue(context.Background(), "BAR-1")
+	if !IsNotFound(err) {
+		t.Fatalf("IsNotFound(%v) = false, want true", err)
+	}
+	if IsUnauthorized(err) {
+		t.Errorf("IsUnauthorized(%v) = true, want false", err)
+	}
+	want := "backlog: 404 No such issue."
+	if err.Error() != want {
+		t.Errorf("ErrorResponse.Error() = %v, want %v", err.Error(), want)
+	}
+}
+
+func TestClient_rateLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxRetries int
+		limited    int
+		wantCalls  int
+		wantErr    bool
+	}{
+		{
+			maxRetries: 3,
+			limited:    2,
+			wantCalls:  3,
+		},
+		{
+			maxRetries: 1,
+			limited:    2,
+			wantCalls:  2,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls <= tt.limited {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				fmt.Fprint(w, `{"spaceKey":"foo"}`)
+			}).SetMaxRetries(tt.maxRetries)
+			_, err := c.GetSpace(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Client.GetSpace() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("Client.GetSpace() calls = %v, want %v", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestClient_timeout(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}).SetTimeout(10 * time.Millisecond)
+	if _, err := c.GetSpace(context.Background()); err == nil {
+		t.Error("Client.GetSpace() error = nil, want timeout")
+	}
+}
+
+func TestClient_rateLimitReset(t *testing.T) {
+	tests := []struct {
+		name string
+		// reset is the seconds until X-RateLimit-Reset.
+		reset     int64
+		ctxTime   time.Duration
+		wantCalls int
+		wantErr   bool
+	}{
+		{
+			name:      "wait longer than the timeout",
+			reset:     2,
+			ctxTime:   0,
+			wantCalls: 2,
+		},
+		{
+			name:      "wait beyond the deadline",
+			reset:     30,
+			ctxTime:   time.Second,
+			wantCalls: 1,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls == 1 {
+					reset := time.Now().Unix() + tt.reset
+					w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				fmt.Fprint(w, `{"spaceKey":"foo"}`)
+			}).SetTimeout(200 * time.Millisecond)
+			ctx := context.Background()
+			if tt.ctxTime > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.ctxTime)
+				defer cancel()
+			}
+			_, err := c.GetSpace(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Client.GetSpace() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !hasStatus(err, http.StatusTooManyRequests) {
+				t.Errorf("Client.GetSpace() error = %v, want 429", err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("Client.GetSpace() calls = %v, want %v", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func Test_retryWait(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	tests := []struct {
+		name    string
+		header  http.Header
+		attempt int
+		want    time.Duration
+	}{
+		{
+			header:  http.Header{},
+			attempt: 2,
+			want:    4 * time.Second,
+		},
+		{
+			header:  http.Header{"Retry-After": []string{"5"}},
+			attempt: 0,
+			want:    5 * time.Second,
+		},
+		{
+			header:  http.Header{"X-Ratelimit-Reset": []string{"1600000030"}},
+			attempt: 0,
+			want:    30 * time.Second,
+		},
+		{
+			header:  http.Header{"X-Ratelimit-Reset": []string{"1600000600"}},
+			attempt: 0,
+			want:    maxRetryWait,
+		},
+		{
+			header:  http.Header{"X-Ratelimit-Reset": []string{"1599999990"}},
+			attempt: 0,
+			want:    0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryWait(tt.header, tt.attempt, now); got != tt.want {
+				t.Errorf("retryWait() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_paginate(t *testing.T) {
+	tests := []struct {
+		name       string
+		limit      int
+		total      int
+		wantCounts []int
+	}{
+		{
+			limit:      0,
+			total:      250,
+			wantCounts: []int{100, 100, 100},
+		},
+		{
+			limit:      0,
+			total:      200,
+			wantCounts: []int{100, 100, 100},
+		},
+		{
+			limit:      150,
+			total:      250,
+			wantCounts: []int{100, 50},
+		},
+		{
+			limit:      20,
+			total:      5,
+			wantCounts: []int{20},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotCounts []int
+			err := paginate(tt.limit, func(offset, count int) (int, error) {
+				gotCounts = append(gotCounts, count)
+				n := tt.total - offset
+				if n > count {
+					n = count
+				}
+				return n, nil
+			})
+			if err != nil {
+				t.Fatalf("paginate() error = %v", err)
+			}
+			if !reflect.DeepEqual(gotCounts, tt.wantCounts) {
+				t.Errorf("paginate() counts = %v, want %v", gotCounts, tt.wantCounts)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package backlog
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// IssueListOptions filters issues.
+type IssueListOptions struct {
+	ProjectIDs   []int
+	StatusIDs    []int
+	AssigneeIDs  []int
+	IssueTypeIDs []int
+	CategoryIDs  []int
+	MilestoneIDs []int
+	Keyword      string
+	// Sort is the attribute to sort by. e.g. "updated", "created", "dueDate"
+	Sort string
+	// Order is "asc" or "desc". Default is "desc".
+	Order string
+	// Limit is the maximum number of issues. Zero means all.
+	Limit int
+}
+
+// GetIssues returns issues, fetching pages until opt.Limit is reached.
+func (c *Client) GetIssues(ctx context.Context, opt *IssueListOptions) ([]*Issue, error) {
+	if opt == nil {
+		opt = &IssueListOptions{}
+	}
+	var issues []*Issue
+	err := paginate(opt.Limit, func(offset, count int) (int, error) {
+		q := url.Values{}
+		addInts(q, "projectId[]", opt.ProjectIDs)
+		addInts(q, "statusId[]", opt.StatusIDs)
+		addInts(q, "assigneeId[]", opt.AssigneeIDs)
+		addInts(q, "issueTypeId[]", opt.IssueTypeIDs)
+		addInts(q, "categoryId[]", opt.CategoryIDs)
+		addInts(q, "milestoneId[]", opt.MilestoneIDs)
+		if opt.Keyword != "" {
+			q.Set("keyword", opt.Keyword)
+		}
+		if opt.Sort != "" {
+			q.Set("sort", opt.Sort)
+		}
+		if opt.Order != "" {
+			q.Set("order", opt.Order)
+		}
+		q.Set("offset", strconv.Itoa(offset))
+		q.Set("count", strconv.Itoa(count))
+		var page []*Issue
+		if err := c.get(ctx, "/issues", q, &page); err != nil {
+			return 0, err
+		}
+		issues = append(issues, page...)
+		return len(page), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return issues, nil
+}
+
+// GetIssue returns the issue of the given key or ID.
+func (c *Client) GetIssue(ctx context.Context, issueKey string) (*Issue, error) {
+	var issue Issue
+	if err := c.get(ctx, issuePath(issueKey), nil, &issue); err != nil {
+		return nil, err
+	}
+	return &issue, nil
+}
+
+// AddIssueOptions is the content of a new issue. ProjectID, Summary,
+// IssueTypeID and PriorityID are required.
+type AddIssueOptions struct {
+	ProjectID     int
+	Summary       string
+	Description   string
+	IssueTypeID   int
+	PriorityID    int
+	AssigneeID    int
+	MilestoneIDs  []int
+	CategoryIDs   []int
+	DueDate       *time.Time
+	ParentIssueID int
+}
+
+func (c *Client) AddIssue(ctx context.Context, opt *AddIssueOptions) (*Issue, error) {
+	form := url.Values{}
+	form.Set("projectId", strconv.Itoa(opt.ProjectID))
+	form.Set("summary", opt.Summary)
+	form.Set("issueTypeId", strconv.Itoa(opt.IssueTypeID))
+	form.Set("priorityId", strconv.Itoa(opt.PriorityID))
+	if opt.Description != "" {
+		form.Set("description", opt.Description)
+	}
+	if opt.AssigneeID > 0 {
+		form.Set("assigneeId", strconv.Itoa(opt.AssigneeID))
+	}
+	addInts(form, "milestoneId[]", opt.MilestoneIDs)
+	addInts(form, "categoryId[]", opt.CategoryIDs)
+	if opt.DueDate != nil {
+		form.Set("dueDate", opt.DueDate.Format("2006-01-02"))
+	}
+	if opt.ParentIssueID > 0 {
+		form.Set("parentIssueId", strconv.Itoa(opt.ParentIssueID))
+	}
+	var issue Issue
+	if err := c.send(ctx, http.MethodPost, "/issues", form, &issue); err != nil {
+		return nil, err
+	}
+	return &issue, nil
+}
+
+// UpdateIssueOptions is the change of an issue. Zero values are left unchanged.
+type UpdateIssueOptions struct {
+	StatusID   int
+	AssigneeID int
+	Comment    string
+}
+
+func (c *Client) UpdateIssue(ctx context.Context, issueKey string, opt *UpdateIssueOptions) (*Issue, error) {
+	form := url.Values{}
+	if opt.StatusID > 0 {
+		form.Set("statusId", strconv.Itoa(opt.StatusID))
+	}
+	if opt.AssigneeID > 0 {
+		form.Set("assigneeId", strconv.Itoa(opt.AssigneeID))
+	}
+	if opt.Comment != "" {
+		form.Set("comment", opt.Comment)
+	}
+	var issue Issue
+	if err := c.send(ctx, http.MethodPatch, issuePath(issueKey), form, &issue); err != nil {
+		return nil, err
+	}
+	return &issue, nil
+}
+
+func (c *Client) GetIssueComments(ctx context.Context, issueKey string, opt *CommentListOptions) ([]*Comment, error) {
+	return c.getComments(ctx, issuePath(issueKey)+"/comments", opt)
+}
+
+func (c *Client) AddIssueComment(ctx context.Context, issueKey string, opt *AddCommentOptions) (*Comment, error) {
+	return c.addComment(ctx, issuePath(issueKey)+"/comments", opt)
+}
+
+func issuePath(issueKey string) string {
+	return "/issues/" + url.PathEscape(issueKey)
+}
@@ -0,0 +1,167 @@
+package backlog
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClient_GetIssues(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/api/v2/issues" ||
+			!reflect.DeepEqual(q["projectId[]"], []string{"1"}) ||
+			!reflect.DeepEqual(q["statusId[]"], []string{"1", "2"}) ||
+			q.Get("keyword") != "bug" || q.Get("sort") != "updated" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode([]*Issue{{IssueKey: "BAR-1"}, {IssueKey: "BAR-2"}})
+	})
+	issues, err := c.GetIssues(context.Background(), &IssueListOptions{
+		ProjectIDs: []int{1},
+		StatusIDs:  []int{IssueStatusOpen, IssueStatusInProgress},
+		Keyword:    "bug",
+		Sort:       "updated",
+	})
+	if err != nil {
+		t.Fatalf("Client.GetIssues() error = %v", err)
+	}
+	if len(issues) != 2 || issues[1].IssueKey != "BAR-2" {
+		t.Errorf("Client.GetIssues() = %v", issues)
+	}
+}
+
+func TestClient_AddIssue(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v2/issues" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		want := url.Values{
+			"projectId":     {"1"},
+			"summary":       {"Fix the login"},
+			"issueTypeId":   {"2"},
+			"priorityId":    {"3"},
+			"assigneeId":    {"4"},
+			"milestoneId[]": {"5"},
+			"categoryId[]":  {"6"},
+			"dueDate":       {"2020-01-31"},
+			"parentIssueId": {"7"},
+		}
+		if !reflect.DeepEqual(r.PostForm, want) {
+			t.Errorf("form = %v, want %v", r.PostForm, want)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(&Issue{IssueKey: "BAR-8"})
+	})
+	due := time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC)
+	got, err := c.AddIssue(context.Background(), &AddIssueOptions{
+		ProjectID:     1,
+		Summary:       "Fix the login",
+		IssueTypeID:   2,
+		PriorityID:    3,
+		AssigneeID:    4,
+		MilestoneIDs:  []int{5},
+		CategoryIDs:   []int{6},
+		DueDate:       &due,
+		ParentIssueID: 7,
+	})
+	if err != nil {
+		t.Fatalf("Client.AddIssue() error = %v", err)
+	}
+	if got.IssueKey != "BAR-8" {
+		t.Errorf("Client.AddIssue() = %v", got)
+	}
+}
+
+func TestClient_UpdateIssue(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || r.URL.Path != "/api/v2/issues/BAR-1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if _, ok := r.PostForm["comment"]; ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(&Issue{
+			IssueKey: "BAR-1",
+			Status:   &Status{ID: atoi(r.PostForm.Get("statusId"))},
+			Assignee: &User{ID: atoi(r.PostForm.Get("assigneeId"))},
+		})
+	})
+	got, err := c.UpdateIssue(context.Background(), "BAR-1", &UpdateIssueOptions{
+		StatusID:   IssueStatusInProgress,
+		AssigneeID: 5,
+	})
+	if err != nil {
+		t.Fatalf("Client.UpdateIssue() error = %v", err)
+	}
+	want := &Issue{IssueKey: "BAR-1", Status: &Status{ID: 2}, Assignee: &User{ID: 5}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Client.UpdateIssue() = %+v, want %+v", got, want)
+	}
+}
+
+func TestClient_AddIssueComment(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v2/issues/BAR-1/comments" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if got := r.PostForm["attachmentId[]"]; !reflect.DeepEqual(got, []string{"7", "8"}) {
+			t.Errorf("attachmentId[] = %v", got)
+		}
+		_ = json.NewEncoder(w).Encode(&Comment{ID: 1, Content: r.PostForm.Get("content")})
+	})
+	got, err := c.AddIssueComment(context.Background(), "BAR-1", &AddCommentOptions{Content: "LGTM", AttachmentIDs: []int{7, 8}})
+	if err != nil {
+		t.Fatalf("Client.AddIssueComment() error = %v", err)
+	}
+	if got.Content != "LGTM" {
+		t.Errorf("Client.AddIssueComment() = %+v", got)
+	}
+}
+
+func TestClient_UploadAttachment(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v2/space/attachment" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		f, h, err := r.FormFile("file")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		b, _ := ioutil.ReadAll(f)
+		_ = json.NewEncoder(w).Encode(&Attachment{ID: 7, Name: h.Filename, Size: int64(len(b))})
+	})
+	got, err := c.UploadAttachment(context.Background(), "log.txt", strings.NewReader("failed"))
+	if err != nil {
+		t.Fatalf("Client.UploadAttachment() error = %v", err)
+	}
+	if want := (&Attachment{ID: 7, Name: "log.txt", Size: 6}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Client.UploadAttachment() = %+v, want %+v", got, want)
+	}
+}
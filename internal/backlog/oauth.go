@@ -0,0 +1,71 @@
+package backlog
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Token is an OAuth 2.0 token of Backlog.
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Expiry returns when the access token expires, counting from now.
+func (t *Token) Expiry(now time.Time) time.Time {
+	return now.Add(time.Duration(t.ExpiresIn) * time.Second)
+}
+
+// OAuthConfig is the OAuth 2.0 application registered on Backlog Developer.
+type OAuthConfig struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+}
+
+// AuthorizationURL returns the URL to ask the user for authorization.
+func (c *Client) AuthorizationURL(conf *OAuthConfig, state string) string {
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", conf.ClientID)
+	q.Set("redirect_uri", conf.RedirectURI)
+	q.Set("state", state)
+	return c.baseURL + "/OAuth2AccessRequest.action?" + q.Encode()
+}
+
+// ExchangeCode exchanges the authorization code for a token.
+func (c *Client) ExchangeCode(ctx context.Context, conf *OAuthConfig, code string) (*Token, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", conf.RedirectURI)
+	return c.token(ctx, conf, form)
+}
+
+// RefreshToken issues a new token with the refresh token.
+func (c *Client) RefreshToken(ctx context.Context, conf *OAuthConfig, refreshToken string) (*Token, error) {
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", refreshToken)
+	return c.token(ctx, conf, form)
+}
+
+func (c *Client) token(ctx context.Context, conf *OAuthConfig, form url.Values) (*Token, error) {
+	form.Set("client_id", conf.ClientID)
+	form.Set("client_secret", conf.ClientSecret)
+	var t Token
+	err := c.do(ctx, &request{
+		method:      http.MethodPost,
+		path:        "/oauth2/token",
+		body:        []byte(form.Encode()),
+		contentType: "application/x-www-form-urlencoded",
+	}, &t)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
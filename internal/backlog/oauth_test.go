@@ -0,0 +1,67 @@
+package backlog
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestClient_AuthorizationURL(t *testing.T) {
+	c := NewClient("https://foo.backlog.com/")
+	got := c.AuthorizationURL(&OAuthConfig{
+		ClientID:    "client",
+		RedirectURI: "http://localhost:8765/callback",
+	}, "state")
+	u, err := url.Parse(got)
+	if err != nil {
+		t.Fatalf("url.Parse() error = %v", err)
+	}
+	if u.Host != "foo.backlog.com" || u.Path != "/OAuth2AccessRequest.action" {
+		t.Errorf("Client.AuthorizationURL() = %v", got)
+	}
+	want := url.Values{
+		"response_type": {"code"},
+		"client_id":     {"client"},
+		"redirect_uri":  {"http://localhost:8765/callback"},
+		"state":         {"state"},
+	}
+	if !reflect.DeepEqual(u.Query(), want) {
+		t.Errorf("Client.AuthorizationURL() query = %v, want %v", u.Query(), want)
+	}
+}
+
+func TestClient_ExchangeCode(t *testing.T) {
+	conf := &OAuthConfig{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURI:  "http://localhost:8765/callback",
+	}
+	api := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v2/oauth2/token" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("code") != "code" ||
+			r.PostForm.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"access","token_type":"Bearer","expires_in":3600,"refresh_token":"refresh"}`)
+	})
+	c := NewClient(api.BaseURL()).SetHTTPClient(api.httpClient)
+	got, err := c.ExchangeCode(context.Background(), conf, "code")
+	if err != nil {
+		t.Fatalf("Client.ExchangeCode() error = %v", err)
+	}
+	want := &Token{AccessToken: "access", TokenType: "Bearer", ExpiresIn: 3600, RefreshToken: "refresh"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Client.ExchangeCode() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,90 @@
+package backlog
+
+import (
+	"context"
+	"net/url"
+)
+
+func (c *Client) GetProjects(ctx context.Context) ([]*Project, error) {
+	var projects []*Project
+	if err := c.get(ctx, "/projects", nil, &projects); err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+func (c *Client) GetProject(ctx context.Context, projectKey string) (*Project, error) {
+	var p Project
+	if err := c.get(ctx, "/projects/"+url.PathEscape(projectKey), nil, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (c *Client) GetProjectUsers(ctx context.Context, projectKey string) ([]*User, error) {
+	var users []*User
+	if err := c.get(ctx, "/projects/"+url.PathEscape(projectKey)+"/users", nil, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetStatuses returns the issue statuses of the project.
+func (c *Client) GetStatuses(ctx context.Context, projectKey string) ([]*Status, error) {
+	var statuses []*Status
+	if err := c.get(ctx, "/projects/"+url.PathEscape(projectKey)+"/statuses", nil, &statuses); err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// GetIssueTypes returns the issue types of the project.
+func (c *Client) GetIssueTypes(ctx context.Context, projectKey string) ([]*IssueType, error) {
+	var types []*IssueType
+	if err := c.get(ctx, "/projects/"+url.PathEscape(projectKey)+"/issueTypes", nil, &types); err != nil {
+		return nil, err
+	}
+	return types, nil
+}
+
+// GetCategories returns the categories of the project.
+func (c *Client) GetCategories(ctx context.Context, projectKey string) ([]*Category, error) {
+	var categories []*Category
+	if err := c.get(ctx, "/projects/"+url.PathEscape(projectKey)+"/categories", nil, &categories); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+// GetVersions returns the versions and milestones of the project.
+func (c *Client) GetVersions(ctx context.Context, projectKey string) ([]*Version, error) {
+	var versions []*Version
+	if err := c.get(ctx, "/projects/"+url.PathEscape(projectKey)+"/versions", nil, &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (c *Client) GetRepositories(ctx context.Context, projectKey string) ([]*Repository, error) {
+	var repos []*Repository
+	if err := c.get(ctx, repositoriesPath(projectKey), nil, &repos); err != nil {
+		return nil, err
+	}
+	return repos, nil
+}
+
+func (c *Client) GetRepository(ctx context.Context, projectKey, repoName string) (*Repository, error) {
+	var r Repository
+	if err := c.get(ctx, repositoryPath(projectKey, repoName), nil, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func repositoriesPath(projectKey string) string {
+	return "/projects/" + url.PathEscape(projectKey) + "/git/repositories"
+}
+
+func repositoryPath(projectKey, repoName string) string {
+	return repositoriesPath(projectKey) + "/" + url.PathEscape(repoName)
+}
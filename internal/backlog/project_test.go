@@ -0,0 +1,50 @@
+package backlog
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestClient_projectAttributes(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/projects/BAR/issueTypes":
+			_ = json.NewEncoder(w).Encode([]*IssueType{{ID: 1, Name: "Bug"}})
+		case "/api/v2/projects/BAR/categories":
+			_ = json.NewEncoder(w).Encode([]*Category{{ID: 2, Name: "Frontend"}})
+		case "/api/v2/projects/BAR/versions":
+			_ = json.NewEncoder(w).Encode([]*Version{{ID: 3, Name: "v1.0"}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	ctx := context.Background()
+	types, err := c.GetIssueTypes(ctx, "BAR")
+	if err != nil || len(types) != 1 || types[0].Name != "Bug" {
+		t.Errorf("Client.GetIssueTypes() = %v, %v", types, err)
+	}
+	categories, err := c.GetCategories(ctx, "BAR")
+	if err != nil || len(categories) != 1 || categories[0].Name != "Frontend" {
+		t.Errorf("Client.GetCategories() = %v, %v", categories, err)
+	}
+	versions, err := c.GetVersions(ctx, "BAR")
+	if err != nil || len(versions) != 1 || versions[0].Name != "v1.0" {
+		t.Errorf("Client.GetVersions() = %v, %v", versions, err)
+	}
+}
+
+func TestClient_GetPriorities(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/priorities" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode([]*Priority{{ID: 2, Name: "High"}, {ID: 3, Name: "Normal"}})
+	})
+	got, err := c.GetPriorities(context.Background())
+	if err != nil || len(got) != 2 || got[1].Name != "Normal" {
+		t.Errorf("Client.GetPriorities() = %v, %v", got, err)
+	}
+}
@@ -0,0 +1,167 @@
+package backlog
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// PullRequestListOptions filters pull requests.
+type PullRequestListOptions struct {
+	StatusIDs      []int
+	AssigneeIDs    []int
+	IssueIDs       []int
+	CreatedUserIDs []int
+	// Offset is the number of pull requests to skip.
+	Offset int
+	// Limit is the maximum number of pull requests. Zero means all.
+	Limit int
+}
+
+// GetPullRequests returns pull requests of the repository, newest first,
+// fetching pages until opt.Limit is reached.
+func (c *Client) GetPullRequests(ctx context.Context, projectKey, repoName string, opt *PullRequestListOptions) ([]*PullRequest, error) {
+	if opt == nil {
+		opt = &PullRequestListOptions{}
+	}
+	var prs []*PullRequest
+	err := paginate(opt.Limit, func(offset, count int) (int, error) {
+		q := url.Values{}
+		addInts(q, "statusId[]", opt.StatusIDs)
+		addInts(q, "assigneeId[]", opt.AssigneeIDs)
+		addInts(q, "issueId[]", opt.IssueIDs)
+		addInts(q, "createdUserId[]", opt.CreatedUserIDs)
+		q.Set("offset", strconv.Itoa(opt.Offset+offset))
+		q.Set("count", strconv.Itoa(count))
+		var page []*PullRequest
+		if err := c.get(ctx, pullRequestsPath(projectKey, repoName), q, &page); err != nil {
+			return 0, err
+		}
+		prs = append(prs, page...)
+		return len(page), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return prs, nil
+}
+
+func (c *Client) GetPullRequest(ctx context.Context, projectKey, repoName string, number int) (*PullRequest, error) {
+	var pr PullRequest
+	if err := c.get(ctx, pullRequestPath(projectKey, repoName, number), nil, &pr); err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
+
+// AddPullRequestOptions is the content of a new pull request.
+type AddPullRequestOptions struct {
+	Summary         string
+	Description     string
+	Base            string
+	Branch          string
+	IssueID         int
+	AssigneeID      int
+	NotifiedUserIDs []int
+}
+
+func (c *Client) AddPullRequest(ctx context.Context, projectKey, repoName string, opt *AddPullRequestOptions) (*PullRequest, error) {
+	form := url.Values{}
+	form.Set("summary", opt.Summary)
+	form.Set("description", opt.Description)
+	form.Set("base", opt.Base)
+	form.Set("branch", opt.Branch)
+	if opt.IssueID > 0 {
+		form.Set("issueId", strconv.Itoa(opt.IssueID))
+	}
+	if opt.AssigneeID > 0 {
+		form.Set("assigneeId", strconv.Itoa(opt.AssigneeID))
+	}
+	addInts(form, "notifiedUserId[]", opt.NotifiedUserIDs)
+	var pr PullRequest
+	if err := c.send(ctx, http.MethodPost, pullRequestsPath(projectKey, repoName), form, &pr); err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
+
+// CommentListOptions pages comments.
+type CommentListOptions struct {
+	// Order is "asc" or "desc". Default is "desc".
+	Order string
+	// Limit is the maximum number of comments. Zero means all.
+	Limit int
+}
+
+func (c *Client) GetPullRequestComments(ctx context.Context, projectKey, repoName string, number int, opt *CommentListOptions) ([]*Comment, error) {
+	return c.getComments(ctx, pullRequestPath(projectKey, repoName, number)+"/comments", opt)
+}
+
+// AddCommentOptions is the content of a new comment.
+type AddCommentOptions struct {
+	Content         string
+	NotifiedUserIDs []int
+	// AttachmentIDs are the IDs of the files given by UploadAttachment. Only
+	// comments of issues support them.
+	AttachmentIDs []int
+}
+
+func (c *Client) AddPullRequestComment(ctx context.Context, projectKey, repoName string, number int, opt *AddCommentOptions) (*Comment, error) {
+	return c.addComment(ctx, pullRequestPath(projectKey, repoName, number)+"/comments", opt)
+}
+
+// getComments pages comments with minId/maxId, as the comment APIs do not
+// support offset.
+func (c *Client) getComments(ctx context.Context, path string, opt *CommentListOptions) ([]*Comment, error) {
+	if opt == nil {
+		opt = &CommentListOptions{}
+	}
+	order := opt.Order
+	if order == "" {
+		order = "desc"
+	}
+	var comments []*Comment
+	err := paginate(opt.Limit, func(offset, count int) (int, error) {
+		q := url.Values{}
+		q.Set("order", order)
+		q.Set("count", strconv.Itoa(count))
+		if n := len(comments); n > 0 {
+			if order == "asc" {
+				q.Set("minId", strconv.Itoa(comments[n-1].ID+1))
+			} else {
+				q.Set("maxId", strconv.Itoa(comments[n-1].ID-1))
+			}
+		}
+		var page []*Comment
+		if err := c.get(ctx, path, q, &page); err != nil {
+			return 0, err
+		}
+		comments = append(comments, page...)
+		return len(page), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (c *Client) addComment(ctx context.Context, path string, opt *AddCommentOptions) (*Comment, error) {
+	form := url.Values{}
+	form.Set("content", opt.Content)
+	addInts(form, "notifiedUserId[]", opt.NotifiedUserIDs)
+	addInts(form, "attachmentId[]", opt.AttachmentIDs)
+	var comment Comment
+	if err := c.send(ctx, http.MethodPost, path, form, &comment); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func pullRequestsPath(projectKey, repoName string) string {
+	return repositoryPath(projectKey, repoName) + "/pullRequests"
+}
+
+func pullRequestPath(projectKey, repoName string, number int) string {
+	return pullRequestsPath(projectKey, repoName) + "/" + strconv.Itoa(number)
+}
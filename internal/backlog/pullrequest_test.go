@@ -0,0 +1,120 @@
+package backlog
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestClient_GetPullRequests(t *testing.T) {
+	var queries []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/projects/BAR/git/repositories/baz/pullRequests" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		q := r.URL.Query()
+		queries = append(queries, q.Get("offset")+"/"+q.Get("count")+"/"+q.Get("statusId[]"))
+		offset, _ := strconv.Atoi(q.Get("offset"))
+		count, _ := strconv.Atoi(q.Get("count"))
+		var page []*PullRequest
+		for i := offset; i < offset+count && i < 120; i++ {
+			page = append(page, &PullRequest{Number: 120 - i})
+		}
+		_ = json.NewEncoder(w).Encode(page)
+	})
+	prs, err := c.GetPullRequests(context.Background(), "BAR", "baz", &PullRequestListOptions{
+		StatusIDs: []int{PullRequestStatusOpen},
+		Limit:     110,
+	})
+	if err != nil {
+		t.Fatalf("Client.GetPullRequests() error = %v", err)
+	}
+	if len(prs) != 110 || prs[0].Number != 120 || prs[109].Number != 11 {
+		t.Errorf("Client.GetPullRequests() returned %d pull requests", len(prs))
+	}
+	wantQueries := []string{"0/100/1", "100/10/1"}
+	if !reflect.DeepEqual(queries, wantQueries) {
+		t.Errorf("Client.GetPullRequests() queries = %v, want %v", queries, wantQueries)
+	}
+}
+
+func TestClient_AddPullRequest(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v2/projects/BAR/git/repositories/baz/pullRequests" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(&PullRequest{
+			Number:  3,
+			Summary: r.PostForm.Get("summary"),
+			Base:    r.PostForm.Get("base"),
+			Branch:  r.PostForm.Get("branch"),
+			Issue:   &Issue{ID: atoi(r.PostForm.Get("issueId"))},
+			Assignee: &User{
+				ID: len(r.PostForm["notifiedUserId[]"]),
+			},
+		})
+	})
+	got, err := c.AddPullRequest(context.Background(), "BAR", "baz", &AddPullRequestOptions{
+		Summary:         "Fix a bug",
+		Base:            "master",
+		Branch:          "feature/BAR-1",
+		IssueID:         10,
+		NotifiedUserIDs: []int{1, 2},
+	})
+	if err != nil {
+		t.Fatalf("Client.AddPullRequest() error = %v", err)
+	}
+	want := &PullRequest{
+		Number:   3,
+		Summary:  "Fix a bug",
+		Base:     "master",
+		Branch:   "feature/BAR-1",
+		Issue:    &Issue{ID: 10},
+		Assignee: &User{ID: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Client.AddPullRequest() = %+v, want %+v", got, want)
+	}
+}
+
+func TestClient_GetPullRequestComments(t *testing.T) {
+	var maxIDs []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		maxIDs = append(maxIDs, q.Get("maxId"))
+		maxID := 250
+		if v := q.Get("maxId"); v != "" {
+			maxID = atoi(v)
+		}
+		var page []*Comment
+		for id := maxID; id > maxID-atoi(q.Get("count")) && id > 0; id-- {
+			page = append(page, &Comment{ID: id})
+		}
+		_ = json.NewEncoder(w).Encode(page)
+	})
+	comments, err := c.GetPullRequestComments(context.Background(), "BAR", "baz", 3, nil)
+	if err != nil {
+		t.Fatalf("Client.GetPullRequestComments() error = %v", err)
+	}
+	if len(comments) != 250 || comments[249].ID != 1 {
+		t.Errorf("Client.GetPullRequestComments() returned %d comments", len(comments))
+	}
+	wantMaxIDs := []string{"", "150", "50"}
+	if !reflect.DeepEqual(maxIDs, wantMaxIDs) {
+		t.Errorf("Client.GetPullRequestComments() maxIds = %v, want %v", maxIDs, wantMaxIDs)
+	}
+}
+
+func atoi(s string) int {
+	i, _ := strconv.Atoi(s)
+	return i
+}
@@ -0,0 +1,51 @@
+package backlog
+
+import (
+	"context"
+	"io"
+)
+
+func (c *Client) GetSpace(ctx context.Context) (*Space, error) {
+	var s Space
+	if err := c.get(ctx, "/space", nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GetMyself returns the user authenticated by the credentials of c.
+func (c *Client) GetMyself(ctx context.Context) (*User, error) {
+	var u User
+	if err := c.get(ctx, "/users/myself", nil, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// GetUsers returns the users of the space. It requires the administrator role.
+func (c *Client) GetUsers(ctx context.Context) ([]*User, error) {
+	var users []*User
+	if err := c.get(ctx, "/users", nil, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetPriorities returns the priorities of issues, which are common to the space.
+func (c *Client) GetPriorities(ctx context.Context) ([]*Priority, error) {
+	var priorities []*Priority
+	if err := c.get(ctx, "/priorities", nil, &priorities); err != nil {
+		return nil, err
+	}
+	return priorities, nil
+}
+
+// UploadAttachment uploads the file to attach to a comment, and returns the
+// attachment whose ID is given to the comment.
+func (c *Client) UploadAttachment(ctx context.Context, name string, r io.Reader) (*Attachment, error) {
+	var attachment Attachment
+	if err := c.upload(ctx, "/space/attachment", name, r, &attachment); err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
@@ -0,0 +1,158 @@
+package backlog
+
+import "time"
+
+type Space struct {
+	SpaceKey string `json:"spaceKey"`
+	Name     string `json:"name"`
+	OwnerID  int    `json:"ownerId"`
+	Lang     string `json:"lang"`
+	Timezone string `json:"timezone"`
+}
+
+type User struct {
+	ID          int    `json:"id"`
+	UserID      string `json:"userId"`
+	Name        string `json:"name"`
+	RoleType    int    `json:"roleType"`
+	Lang        string `json:"lang"`
+	MailAddress string `json:"mailAddress"`
+}
+
+type Project struct {
+	ID         int    `json:"id"`
+	ProjectKey string `json:"projectKey"`
+	Name       string `json:"name"`
+	// TextFormattingRule is "markdown" or "backlog", the notation of the
+	// descriptions and the comments.
+	TextFormattingRule string `json:"textFormattingRule"`
+	Archived           bool   `json:"archived"`
+}
+
+type Repository struct {
+	ID          int        `json:"id"`
+	ProjectID   int        `json:"projectId"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	HTTPURL     string     `json:"httpUrl"`
+	SSHURL      string     `json:"sshUrl"`
+	PushedAt    *time.Time `json:"pushedAt"`
+	Created     time.Time  `json:"created"`
+	Updated     time.Time  `json:"updated"`
+}
+
+// Status is a status of issues or pull requests.
+type Status struct {
+	ID           int    `json:"id"`
+	ProjectID    int    `json:"projectId"`
+	Name         string `json:"name"`
+	Color        string `json:"color"`
+	DisplayOrder int    `json:"displayOrder"`
+}
+
+// Statuses of pull requests.
+const (
+	PullRequestStatusOpen   = 1
+	PullRequestStatusClosed = 2
+	PullRequestStatusMerged = 3
+)
+
+type PullRequest struct {
+	ID           int        `json:"id"`
+	ProjectID    int        `json:"projectId"`
+	RepositoryID int        `json:"repositoryId"`
+	Number       int        `json:"number"`
+	Summary      string     `json:"summary"`
+	Description  string     `json:"description"`
+	Base         string     `json:"base"`
+	Branch       string     `json:"branch"`
+	Status       *Status    `json:"status"`
+	Assignee     *User      `json:"assignee"`
+	Issue        *Issue     `json:"issue"`
+	BaseCommit   string     `json:"baseCommit"`
+	BranchCommit string     `json:"branchCommit"`
+	MergeCommit  string     `json:"mergeCommit"`
+	CloseAt      *time.Time `json:"closeAt"`
+	MergeAt      *time.Time `json:"mergeAt"`
+	CreatedUser  *User      `json:"createdUser"`
+	Created      time.Time  `json:"created"`
+	UpdatedUser  *User      `json:"updatedUser"`
+	Updated      time.Time  `json:"updated"`
+}
+
+type Attachment struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+type Comment struct {
+	ID          int       `json:"id"`
+	Content     string    `json:"content"`
+	CreatedUser *User     `json:"createdUser"`
+	Created     time.Time `json:"created"`
+	Updated     time.Time `json:"updated"`
+}
+
+// Statuses of issues which every project has.
+const (
+	IssueStatusOpen       = 1
+	IssueStatusInProgress = 2
+	IssueStatusResolved   = 3
+	IssueStatusClosed     = 4
+)
+
+type Issue struct {
+	ID            int         `json:"id"`
+	ProjectID     int         `json:"projectId"`
+	IssueKey      string      `json:"issueKey"`
+	KeyID         int         `json:"keyId"`
+	IssueType     *IssueType  `json:"issueType"`
+	Summary       string      `json:"summary"`
+	Description   string      `json:"description"`
+	Priority      *Priority   `json:"priority"`
+	Status        *Status     `json:"status"`
+	Assignee      *User       `json:"assignee"`
+	Category      []*Category `json:"category"`
+	Versions      []*Version  `json:"versions"`
+	Milestone     []*Version  `json:"milestone"`
+	StartDate     *time.Time  `json:"startDate"`
+	DueDate       *time.Time  `json:"dueDate"`
+	ParentIssueID *int        `json:"parentIssueId"`
+	CreatedUser   *User       `json:"createdUser"`
+	Created       time.Time   `json:"created"`
+	UpdatedUser   *User       `json:"updatedUser"`
+	Updated       time.Time   `json:"updated"`
+}
+
+type IssueType struct {
+	ID        int    `json:"id"`
+	ProjectID int    `json:"projectId"`
+	Name      string `json:"name"`
+	Color     string `json:"color"`
+}
+
+// Priorities of issues, which are common to spaces.
+const (
+	PriorityHigh   = 2
+	PriorityNormal = 3
+	PriorityLow    = 4
+)
+
+type Priority struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type Category struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Version is a version or a milestone of a project.
+type Version struct {
+	ID        int    `json:"id"`
+	ProjectID int    `json:"projectId"`
+	Name      string `json:"name"`
+	Archived  bool   `json:"archived"`
+}
@@ -0,0 +1,722 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/vvatanabe/gitb/internal/backlog"
+)
+
+// ListIssuesOptions filters issues of `gitb issue list`.
+type ListIssuesOptions struct {
+	// State is one of the values of IssueStatusFromString, or the name of a
+	// status of the project.
+	State string
+	// Assignee is the user ID or the name of the assignee, or "me".
+	Assignee  string
+	Milestone string
+	Category  string
+	Type      string
+	Keyword   string
+	// Sort is the attribute to sort by, e.g. "updated", "created", "dueDate".
+	Sort string
+	// Limit is the maximum number of issues. Zero means all.
+	Limit int
+}
+
+// ListIssues fetches issues of the project of the repository.
+func (b *BacklogRepository) ListIssues(opt *ListIssuesOptions) ([]*backlog.Issue, error) {
+	ctx := context.Background()
+	client, err := b.APIClient()
+	if err != nil {
+		return nil, err
+	}
+	project, err := client.GetProject(ctx, b.projectKey)
+	if err != nil {
+		return nil, err
+	}
+	list := &backlog.IssueListOptions{
+		ProjectIDs: []int{project.ID},
+		Keyword:    opt.Keyword,
+		Sort:       opt.Sort,
+		Limit:      opt.Limit,
+	}
+	if list.StatusIDs, err = b.issueStatusIDs(ctx, client, opt.State); err != nil {
+		return nil, err
+	}
+	if opt.Assignee != "" {
+		u, err := b.findAssignee(ctx, client, opt.Assignee)
+		if err != nil {
+			return nil, err
+		}
+		list.AssigneeIDs = []int{u.ID}
+	}
+	if opt.Milestone != "" {
+		versions, err := client.GetVersions(ctx, b.projectKey)
+		if err != nil {
+			return nil, err
+		}
+		v, err := findVersion(versions, opt.Milestone)
+		if err != nil {
+			return nil, err
+		}
+		list.MilestoneIDs = []int{v.ID}
+	}
+	if opt.Category != "" {
+		categories, err := client.GetCategories(ctx, b.projectKey)
+		if err != nil {
+			return nil, err
+		}
+		c, err := findCategory(categories, opt.Category)
+		if err != nil {
+			return nil, err
+		}
+		list.CategoryIDs = []int{c.ID}
+	}
+	if opt.Type != "" {
+		types, err := client.GetIssueTypes(ctx, b.projectKey)
+		if err != nil {
+			return nil, err
+		}
+		t, err := findIssueType(types, opt.Type)
+		if err != nil {
+			return nil, err
+		}
+		list.IssueTypeIDs = []int{t.ID}
+	}
+	return client.GetIssues(ctx, list)
+}
+
+// CreateIssueOptions is the content of a new issue of `gitb issue create`.
+// Names are resolved into IDs in the project.
+type CreateIssueOptions struct {
+	Title string
+	Body  string
+	// Type is the name of the issue type. Empty means the first of the project.
+	Type string
+	// Priority is the name of the priority. Empty means Normal.
+	Priority string
+	// Assignee is the user ID or the name of the assignee, or "me".
+	Assignee  string
+	Milestone string
+	Category  string
+	// Due is the due date in YYYY-MM-DD.
+	Due string
+	// Parent is the issue key of the parent issue.
+	Parent string
+}
+
+// CreateIssue adds an issue to the project of the repository, and returns
+// its key and URL.
+func (b *BacklogRepository) CreateIssue(opt *CreateIssueOptions) (key, url string, err error) {
+	if opt.Title == "" {
+		return "", "", errors.New("title is required")
+	}
+	ctx := context.Background()
+	client, err := b.APIClient()
+	if err != nil {
+		return "", "", err
+	}
+	project, err := client.GetProject(ctx, b.projectKey)
+	if err != nil {
+		return "", "", err
+	}
+	add := &backlog.AddIssueOptions{
+		ProjectID:   project.ID,
+		Summary:     opt.Title,
+		Description: opt.Body,
+		PriorityID:  backlog.PriorityNormal,
+	}
+	types, err := client.GetIssueTypes(ctx, b.projectKey)
+	if err != nil {
+		return "", "", err
+	}
+	if opt.Type == "" {
+		if len(types) == 0 {
+			return "", "", errors.New("the project has no issue types")
+		}
+		add.IssueTypeID = types[0].ID
+	} else {
+		t, err := findIssueType(types, opt.Type)
+		if err != nil {
+			return "", "", err
+		}
+		add.IssueTypeID = t.ID
+	}
+	if opt.Priority != "" {
+		priorities, err := client.GetPriorities(ctx)
+		if err != nil {
+			return "", "", err
+		}
+		p, err := findPriority(priorities, opt.Priority)
+		if err != nil {
+			return "", "", err
+		}
+		add.PriorityID = p.ID
+	}
+	if opt.Assignee != "" {
+		u, err := b.findAssignee(ctx, client, opt.Assignee)
+		if err != nil {
+			return "", "", err
+		}
+		add.AssigneeID = u.ID
+	}
+	if opt.Milestone != "" {
+		versions, err := client.GetVersions(ctx, b.projectKey)
+		if err != nil {
+			return "", "", err
+		}
+		v, err := findVersion(versions, opt.Milestone)
+		if err != nil {
+			return "", "", err
+		}
+		add.MilestoneIDs = []int{v.ID}
+	}
+	if opt.Category != "" {
+		categories, err := client.GetCategories(ctx, b.projectKey)
+		if err != nil {
+			return "", "", err
+		}
+		c, err := findCategory(categories, opt.Category)
+		if err != nil {
+			return "", "", err
+		}
+		add.CategoryIDs = []int{c.ID}
+	}
+	if opt.Due != "" {
+		due, err := time.Parse("2006-01-02", opt.Due)
+		if err != nil {
+			return "", "", errors.Errorf("invalid due date %s. use YYYY-MM-DD", opt.Due)
+		}
+		add.DueDate = &due
+	}
+	if opt.Parent != "" {
+		parent, err := client.GetIssue(ctx, opt.Parent)
+		if err != nil {
+			return "", "", errors.Wrapf(err, "could not get the parent issue %s", opt.Parent)
+		}
+		add.ParentIssueID = parent.ID
+	}
+	issue, err := client.AddIssue(ctx, add)
+	if err != nil {
+		return "", "", err
+	}
+	return issue.IssueKey, b.urlBuilder().IssueURL(issue.IssueKey), nil
+}
+
+func findPriority(priorities []*backlog.Priority, name string) (*backlog.Priority, error) {
+	for _, p := range priorities {
+		if strings.EqualFold(p.Name, name) {
+			return p, nil
+		}
+	}
+	return nil, errors.Errorf("could not find the priority %s", name)
+}
+
+const issueTemplateHelp = `# Write the title in the front matter, and the description below it.
+# Empty fields are left to the defaults. These lines are removed.
+# An empty title aborts creating the issue.
+`
+
+// renderIssueTemplate returns the text to edit the issue of opt in the editor:
+// the fields in the front matter, and the description below it.
+func renderIssueTemplate(opt *CreateIssueOptions) string {
+	var sb strings.Builder
+	sb.WriteString("---\n")
+	for _, f := range issueTemplateFields(opt) {
+		fmt.Fprintf(&sb, "%s: %s\n", f.name, *f.value)
+	}
+	sb.WriteString("---\n")
+	sb.WriteString(issueTemplateHelp)
+	if opt.Body != "" {
+		sb.WriteString(opt.Body)
+		if !strings.HasSuffix(opt.Body, "\n") {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+// parseIssueTemplate reads the text edited from renderIssueTemplate into opt.
+func parseIssueTemplate(text string, opt *CreateIssueOptions) error {
+	fields := issueTemplateFields(opt)
+	help := map[string]bool{}
+	for _, line := range strings.Split(issueTemplateHelp, "\n") {
+		help[line] = true
+	}
+	sc := bufio.NewScanner(strings.NewReader(text))
+	var body []string
+	inFrontMatter, seen := false, 0
+	for sc.Scan() {
+		line := sc.Text()
+		// Lines starting with '#' are kept, which are headings in Markdown.
+		if help[line] && line != "" {
+			continue
+		}
+		if strings.TrimSpace(line) == "---" && seen < 2 {
+			seen++
+			inFrontMatter = seen == 1
+			continue
+		}
+		if !inFrontMatter {
+			body = append(body, line)
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			return errors.Errorf("invalid line in the front matter: %s", line)
+		}
+		name, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		found := false
+		for _, f := range fields {
+			if f.name == name {
+				*f.value = value
+				found = true
+			}
+		}
+		if !found {
+			return errors.Errorf("unknown field in the front matter: %s", name)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if inFrontMatter {
+		return errors.New("the front matter is not closed with ---")
+	}
+	opt.Body = strings.TrimSpace(strings.Join(body, "\n"))
+	return nil
+}
+
+type issueTemplateField struct {
+	name  string
+	value *string
+}
+
+func issueTemplateFields(opt *CreateIssueOptions) []issueTemplateField {
+	return []issueTemplateField{
+		{"title", &opt.Title},
+		{"type", &opt.Type},
+		{"priority", &opt.Priority},
+		{"assignee", &opt.Assignee},
+		{"milestone", &opt.Milestone},
+		{"category", &opt.Category},
+		{"due", &opt.Due},
+		{"parent", &opt.Parent},
+	}
+}
+
+// readBodyFile reads the description or the comment from file. "-" is
+// stdin.
+func readBodyFile(file string) (string, error) {
+	var b []byte
+	var err error
+	if file == "-" {
+		if b, err = ioutil.ReadAll(os.Stdin); err != nil {
+			return "", errors.Wrap(err, "could not read stdin")
+		}
+	} else if b, err = ioutil.ReadFile(file); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\n"), nil
+}
+
+// EditIssue lets the user edit the issue of opt in the editor.
+func EditIssue(opt *CreateIssueOptions) error {
+	text, err := editText("ISSUE_EDITMSG.md", renderIssueTemplate(opt))
+	if err != nil {
+		return err
+	}
+	if err := parseIssueTemplate(text, opt); err != nil {
+		return err
+	}
+	if opt.Title == "" {
+		return errors.New("aborting due to the empty title")
+	}
+	return nil
+}
+
+// StartIssueOptions is the options of `gitb issue start`.
+type StartIssueOptions struct {
+	// Branch is the template of the branch name. {key} is replaced with the
+	// issue key, and {slug} with the slug of the summary.
+	Branch string
+	// Base is the branch of the remote to start from. Default is the default
+	// branch of the remote.
+	Base string
+}
+
+// StartIssue checks out a new branch for the issue from the base branch of
+// the remote, and sets the issue in progress and assigned to the user logged
+// in. It returns the name of the branch. An existing branch of the same name
+// is checked out as it is.
+func (b *BacklogRepository) StartIssue(key string, opt *StartIssueOptions) (string, error) {
+	ctx := context.Background()
+	client, err := b.APIClient()
+	if err != nil {
+		return "", err
+	}
+	issue, err := client.GetIssue(ctx, key)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not get the issue %s", key)
+	}
+	branch, err := b.issueBranchName(opt.Branch, issue)
+	if err != nil {
+		return "", err
+	}
+	myself, err := client.GetMyself(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var argv []string
+	if b.repo.Git("rev-parse", "--verify", "--quiet", refBranchPrefix+branch).Run() == nil {
+		argv = []string{"checkout", branch}
+	} else {
+		base := opt.Base
+		if base == "" {
+			if base, err = b.repo.DefaultBranch(); err != nil {
+				return "", err
+			}
+		}
+		if err := b.repo.Fetch(base); err != nil {
+			return "", errors.Wrapf(err, "could not fetch %s", base)
+		}
+		// Without --no-track, the branch would push to the base branch.
+		argv = []string{"checkout", "--no-track", "-b", branch, "refs/remotes/" + b.repo.RemoteName() + "/" + base}
+	}
+	cmd := b.repo.Git(argv...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", errors.Wrapf(err, "git %s", strings.Join(argv, " "))
+	}
+
+	update := &backlog.UpdateIssueOptions{}
+	if issue.Status == nil || issue.Status.ID != backlog.IssueStatusInProgress {
+		update.StatusID = backlog.IssueStatusInProgress
+	}
+	if issue.Assignee == nil || issue.Assignee.ID != myself.ID {
+		update.AssigneeID = myself.ID
+	}
+	if update.StatusID > 0 || update.AssigneeID > 0 {
+		if _, err := client.UpdateIssue(ctx, issue.IssueKey, update); err != nil {
+			return "", errors.Wrapf(err, "could not update the issue %s", issue.IssueKey)
+		}
+	}
+	return branch, nil
+}
+
+// issueBranchName returns the branch name of issue from template. The issue
+// key must be found in the name by extractIssueKey, so that the branch is
+// related to the issue by the other commands.
+func (b *BacklogRepository) issueBranchName(template string, issue *backlog.Issue) (string, error) {
+	if !strings.Contains(template, "{key}") {
+		return "", errors.Errorf("the branch name %s has no {key}", template)
+	}
+	name := strings.NewReplacer("{key}", issue.IssueKey, "{slug}", slugify(issue.Summary)).Replace(template)
+	// Clean up the separators around an empty slug.
+	for strings.Contains(name, "--") {
+		name = strings.Replace(name, "--", "-", -1)
+	}
+	name = strings.NewReplacer("-/", "/", "/-", "/").Replace(name)
+	name = strings.Trim(name, "-/.")
+	if extractIssueKey(name) != issue.IssueKey {
+		return "", errors.Errorf("the branch name %s is not related to the issue %s. put {key} before the other issue keys", name, issue.IssueKey)
+	}
+	if err := b.repo.Git("check-ref-format", "--branch", name).Run(); err != nil {
+		return "", errors.Errorf("invalid branch name %s", name)
+	}
+	return name, nil
+}
+
+// slugMaxLength is the maximum length of the slug of a branch name.
+const slugMaxLength = 40
+
+// slugify returns the lower-case words of s joined by '-'. Letters other than
+// ASCII ones are dropped, so the slug of a Japanese summary may be empty.
+func slugify(s string) string {
+	slug := strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return r > unicode.MaxASCII || !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), "-")
+	if len(slug) > slugMaxLength {
+		// Cut at the end of a word.
+		if i := strings.LastIndex(slug[:slugMaxLength+1], "-"); i > 0 {
+			slug = slug[:i]
+		} else {
+			slug = slug[:slugMaxLength]
+		}
+	}
+	return slug
+}
+
+// IssueView is an issue with what `gitb issue view` shows with it.
+type IssueView struct {
+	Issue *backlog.Issue
+	URL   string
+	// TextFormattingRule is the notation of the description and the comments.
+	TextFormattingRule string
+	// Comments are the recent comments, oldest first.
+	Comments []*backlog.Comment
+	// PullRequests are the pull requests of the repository linked to the issue.
+	PullRequests []*backlog.PullRequest
+}
+
+// recentCommitsToFindIssueKey is the number of commits whose messages are
+// searched for the issue key when the branch name has none.
+const recentCommitsToFindIssueKey = 10
+
+// findIssueKey returns key when it is given. Otherwise it returns the issue
+// key in the name of the current branch, or the one of the project in the
+// recent commit messages.
+func (b *BacklogRepository) findIssueKey(key string) (string, error) {
+	if key != "" {
+		return key, nil
+	}
+	if key := extractIssueKey(b.repo.HeadShortName()); key != "" {
+		return key, nil
+	}
+	out, err := b.runGit("log", "-n", strconv.Itoa(recentCommitsToFindIssueKey), "--format=%B")
+	if err == nil {
+		if key := extractProjectIssueKey(out, b.projectKey); key != "" {
+			return key, nil
+		}
+	}
+	return "", errors.New("could not find issue key in current branch name or recent commits. specify ISSUE-KEY")
+}
+
+// ViewIssue fetches the issue of key with its recent comments, at most
+// comments of them, and the pull requests linked to it. When key is empty,
+// the issue is found by findIssueKey.
+func (b *BacklogRepository) ViewIssue(key string, comments int) (*IssueView, error) {
+	key, err := b.findIssueKey(key)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	client, err := b.APIClient()
+	if err != nil {
+		return nil, err
+	}
+	issue, err := client.GetIssue(ctx, key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get the issue %s", key)
+	}
+	project, err := client.GetProject(ctx, strconv.Itoa(issue.ProjectID))
+	if err != nil {
+		return nil, err
+	}
+	v := &IssueView{
+		Issue:              issue,
+		URL:                b.urlBuilder().IssueURL(issue.IssueKey),
+		TextFormattingRule: project.TextFormattingRule,
+	}
+	if comments > 0 {
+		// Comments of changes only have no content, so a page is fetched to
+		// find the recent ones with content.
+		all, err := client.GetIssueComments(ctx, issue.IssueKey, &backlog.CommentListOptions{Limit: 100})
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range all {
+			if len(v.Comments) == comments {
+				break
+			}
+			if strings.TrimSpace(c.Content) != "" {
+				v.Comments = append([]*backlog.Comment{c}, v.Comments...)
+			}
+		}
+	}
+	prs, err := client.GetPullRequests(ctx, b.projectKey, b.repoName, &backlog.PullRequestListOptions{
+		IssueIDs: []int{issue.ID},
+	})
+	if err != nil {
+		return nil, err
+	}
+	v.PullRequests = prs
+	return v, nil
+}
+
+// OpenIssueByKey opens the page of the issue of key, found by findIssueKey
+// when it is empty.
+func (b *BacklogRepository) OpenIssueByKey(key string) error {
+	key, err := b.findIssueKey(key)
+	if err != nil {
+		return err
+	}
+	return b.openURL(b.urlBuilder().IssueURL(key))
+}
+
+// PrintIssueView prints the issue of v for the terminal.
+func PrintIssueView(w io.Writer, v *IssueView, color bool) error {
+	issue := v.Issue
+	fmt.Fprintf(w, "%s %s\n", colorize(issue.Summary, sgrBold, color), colorize(issue.IssueKey, colorCyan, color))
+	var fields []string
+	if issue.Status != nil {
+		fields = append(fields, colorize(issue.Status.Name, issueStatusColor(issue.Status.ID), color))
+	}
+	if issue.IssueType != nil {
+		fields = append(fields, issue.IssueType.Name)
+	}
+	if issue.Priority != nil {
+		fields = append(fields, "Priority: "+issue.Priority.Name)
+	}
+	assignee := "-"
+	if issue.Assignee != nil {
+		assignee = issue.Assignee.Name
+	}
+	fields = append(fields, "Assignee: "+assignee)
+	if issue.DueDate != nil {
+		fields = append(fields, "Due: "+issue.DueDate.Format("2006-01-02"))
+	}
+	fmt.Fprintln(w, strings.Join(fields, " • "))
+	fmt.Fprintln(w, colorize(v.URL, colorGray, color))
+
+	if description := strings.TrimSpace(issue.Description); description != "" {
+		fmt.Fprintf(w, "\n%s\n", indent(renderText(description, v.TextFormattingRule, color), "  "))
+	}
+	if len(v.PullRequests) > 0 {
+		fmt.Fprintf(w, "\n%s\n", colorize("Pull requests", sgrBold, color))
+		var rows [][]string
+		for _, pr := range v.PullRequests {
+			status := ""
+			if pr.Status != nil {
+				status = colorize(pr.Status.Name, prStatusColor(pr.Status.ID), color)
+			}
+			rows = append(rows, []string{
+				"  " + colorize("#"+strconv.Itoa(pr.Number), colorCyan, color),
+				status,
+				pr.Summary,
+				pr.Branch + " -> " + pr.Base,
+			})
+		}
+		if err := printTable(w, rows); err != nil {
+			return err
+		}
+	}
+	if len(v.Comments) > 0 {
+		fmt.Fprintf(w, "\n%s\n", colorize("Recent comments", sgrBold, color))
+		for _, c := range v.Comments {
+			author := ""
+			if c.CreatedUser != nil {
+				author = c.CreatedUser.Name
+			}
+			fmt.Fprintf(w, "  %s %s\n", colorize(author, sgrBold, color), colorize(c.Created.Local().Format("2006-01-02 15:04"), colorGray, color))
+			fmt.Fprintf(w, "%s\n", indent(renderText(strings.TrimSpace(c.Content), v.TextFormattingRule, color), "    "))
+		}
+	}
+	return nil
+}
+
+// issueStatusIDs returns the IDs of the statuses of state. A state other than
+// the values of IssueStatusFromString is looked up in the custom statuses of
+// the project.
+func (b *BacklogRepository) issueStatusIDs(ctx context.Context, client *backlog.Client, state string) ([]int, error) {
+	s, err := IssueStatusFromString(state)
+	if err == nil {
+		return s.statusIDs(), nil
+	}
+	statuses, serr := client.GetStatuses(ctx, b.projectKey)
+	if serr != nil {
+		return nil, serr
+	}
+	for _, status := range statuses {
+		if status.Name == state {
+			return []int{status.ID}, nil
+		}
+	}
+	return nil, err
+}
+
+// findAssignee finds the user of s in the project. "me" is the user logged in.
+func (b *BacklogRepository) findAssignee(ctx context.Context, client *backlog.Client, s string) (*backlog.User, error) {
+	if s == "me" {
+		return client.GetMyself(ctx)
+	}
+	users, err := client.GetProjectUsers(ctx, b.projectKey)
+	if err != nil {
+		return nil, err
+	}
+	return findUser(users, s)
+}
+
+func findVersion(versions []*backlog.Version, name string) (*backlog.Version, error) {
+	for _, v := range versions {
+		if v.Name == name {
+			return v, nil
+		}
+	}
+	return nil, errors.Errorf("could not find the milestone %s in the project", name)
+}
+
+func findCategory(categories []*backlog.Category, name string) (*backlog.Category, error) {
+	for _, c := range categories {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	return nil, errors.Errorf("could not find the category %s in the project", name)
+}
+
+func findIssueType(types []*backlog.IssueType, name string) (*backlog.IssueType, error) {
+	for _, t := range types {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return nil, errors.Errorf("could not find the issue type %s in the project", name)
+}
+
+// PrintIssues prints issues as an aligned table, or as f says.
+func PrintIssues(w io.Writer, issues []*backlog.Issue, f *ListFormat) error {
+	if ok, err := f.printList(w, issues); ok {
+		return err
+	}
+	var rows [][]string
+	for _, issue := range issues {
+		status := ""
+		if issue.Status != nil {
+			status = colorize(issue.Status.Name, issueStatusColor(issue.Status.ID), f.Color)
+		}
+		issueType := ""
+		if issue.IssueType != nil {
+			issueType = issue.IssueType.Name
+		}
+		assignee := "-"
+		if issue.Assignee != nil {
+			assignee = issue.Assignee.Name
+		}
+		rows = append(rows, []string{
+			colorize(issue.IssueKey, colorCyan, f.Color),
+			status,
+			issueType,
+			issue.Summary,
+			assignee,
+			issue.Updated.Local().Format("2006-01-02"),
+		})
+	}
+	return printTable(w, rows)
+}
+
+func issueStatusColor(id int) int {
+	switch id {
+	case backlog.IssueStatusOpen:
+		return colorRed
+	case backlog.IssueStatusInProgress:
+		return colorBlue
+	case backlog.IssueStatusResolved:
+		return colorGreen
+	case backlog.IssueStatusClosed:
+		return colorGray
+	}
+	return colorYellow
+}
@@ -0,0 +1,696 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/vvatanabe/gitb/internal/backlog"
+)
+
+func TestBacklogRepository_ListIssues(t *testing.T) {
+	responses := map[string]interface{}{
+		"GET /api/v2/projects/BAR": &backlog.Project{ID: 5, ProjectKey: "BAR"},
+		"GET /api/v2/users/myself": &backlog.User{ID: 1, UserID: "alice", Name: "Alice"},
+		"GET /api/v2/projects/BAR/users": []*backlog.User{
+			{ID: 1, UserID: "alice", Name: "Alice"},
+			{ID: 2, UserID: "bob", Name: "Bob"},
+		},
+		"GET /api/v2/projects/BAR/statuses": []*backlog.Status{
+			{ID: 1, Name: "Open"},
+			{ID: 7, Name: "Reviewing"},
+		},
+		"GET /api/v2/projects/BAR/versions":   []*backlog.Version{{ID: 20, Name: "v1.0"}},
+		"GET /api/v2/projects/BAR/categories": []*backlog.Category{{ID: 30, Name: "Frontend"}},
+		"GET /api/v2/projects/BAR/issueTypes": []*backlog.IssueType{{ID: 40, Name: "Bug"}},
+		"GET /api/v2/issues": []*backlog.Issue{
+			{IssueKey: "BAR-2"},
+			{IssueKey: "BAR-1"},
+		},
+	}
+	tests := []struct {
+		name      string
+		opt       *ListIssuesOptions
+		wantKeys  []string
+		wantQuery url.Values
+		wantErr   bool
+	}{
+		{
+			name:     "not closed",
+			opt:      &ListIssuesOptions{State: "not_closed", Limit: 30},
+			wantKeys: []string{"BAR-2", "BAR-1"},
+			wantQuery: url.Values{
+				"projectId[]": {"5"},
+				"statusId[]":  {"1", "2", "3"},
+				"offset":      {"0"},
+				"count":       {"30"},
+			},
+		},
+		{
+			name: "filters",
+			opt: &ListIssuesOptions{
+				State:     "all",
+				Assignee:  "me",
+				Milestone: "v1.0",
+				Category:  "Frontend",
+				Type:      "Bug",
+				Keyword:   "login",
+				Sort:      "created",
+				Limit:     30,
+			},
+			wantKeys: []string{"BAR-2", "BAR-1"},
+			wantQuery: url.Values{
+				"projectId[]":   {"5"},
+				"assigneeId[]":  {"1"},
+				"milestoneId[]": {"20"},
+				"categoryId[]":  {"30"},
+				"issueTypeId[]": {"40"},
+				"keyword":       {"login"},
+				"sort":          {"created"},
+				"offset":        {"0"},
+				"count":         {"30"},
+			},
+		},
+		{
+			name:     "assignee and custom status",
+			opt:      &ListIssuesOptions{State: "Reviewing", Assignee: "Bob", Limit: 30},
+			wantKeys: []string{"BAR-2", "BAR-1"},
+			wantQuery: url.Values{
+				"projectId[]":  {"5"},
+				"statusId[]":   {"7"},
+				"assigneeId[]": {"2"},
+				"offset":       {"0"},
+				"count":        {"30"},
+			},
+		},
+		{
+			name:    "invalid state",
+			opt:     &ListIssuesOptions{State: "draft"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown milestone",
+			opt:     &ListIssuesOptions{State: "all", Milestone: "v2.0"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forms := make(map[string]url.Values)
+			b := &BacklogRepository{
+				api:        newAPIServer(t, responses, forms),
+				domain:     "backlog.com",
+				spaceKey:   "foo",
+				projectKey: "BAR",
+				repoName:   "baz",
+			}
+			got, err := b.ListIssues(tt.opt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BacklogRepository.ListIssues() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var gotKeys []string
+			for _, issue := range got {
+				gotKeys = append(gotKeys, issue.IssueKey)
+			}
+			if !reflect.DeepEqual(gotKeys, tt.wantKeys) {
+				t.Errorf("BacklogRepository.ListIssues() = %v, want %v", gotKeys, tt.wantKeys)
+			}
+			if tt.wantErr {
+				return
+			}
+			gotQuery := forms["GET /api/v2/issues"]
+			if !reflect.DeepEqual(gotQuery, tt.wantQuery) {
+				t.Errorf("BacklogRepository.ListIssues() query = %v, want %v", gotQuery, tt.wantQuery)
+			}
+		})
+	}
+}
+
+func TestPrintIssues(t *testing.T) {
+	updated := time.Date(2020, 1, 2, 12, 0, 0, 0, time.Local)
+	issues := []*backlog.Issue{
+		{
+			IssueKey:  "BAR-12",
+			Summary:   "Fix the login",
+			IssueType: &backlog.IssueType{Name: "Bug"},
+			Status:    &backlog.Status{ID: 1, Name: "Open"},
+			Assignee:  &backlog.User{Name: "Alice"},
+			Updated:   updated,
+		},
+		{
+			IssueKey:  "BAR-3",
+			Summary:   "Add the list",
+			IssueType: &backlog.IssueType{Name: "Task"},
+			Status:    &backlog.Status{ID: 3, Name: "Resolved"},
+			Updated:   updated,
+		},
+	}
+	tests := []struct {
+		name string
+		f    *ListFormat
+		want string
+	}{
+		{
+			name: "table",
+			f:    &ListFormat{},
+			want: "BAR-12  Open      Bug   Fix the login  Alice  2020-01-02\n" +
+				"BAR-3   Resolved  Task  Add the list   -      2020-01-02\n",
+		},
+		{
+			name: "template",
+			f:    &ListFormat{Template: "{{.IssueKey}}:{{.Status.Name}}:{{date .Updated}}"},
+			want: "BAR-12:Open:2020-01-02\nBAR-3:Resolved:2020-01-02\n",
+		},
+		{
+			name: "color",
+			f:    &ListFormat{Color: true},
+			want: "\x1b[36mBAR-12\x1b[0m  \x1b[31mOpen\x1b[0m      Bug   Fix the login  Alice  2020-01-02\n" +
+				"\x1b[36mBAR-3\x1b[0m   \x1b[32mResolved\x1b[0m  Task  Add the list   -      2020-01-02\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := PrintIssues(&buf, issues, tt.f); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("PrintIssues() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintIssues_wide(t *testing.T) {
+	updated := time.Date(2020, 1, 2, 12, 0, 0, 0, time.Local)
+	issues := []*backlog.Issue{
+		{IssueKey: "BAR-12", Summary: "ログインを直す", IssueType: &backlog.IssueType{Name: "バグ"}, Updated: updated},
+		{IssueKey: "BAR-3", Summary: "Add the list", IssueType: &backlog.IssueType{Name: "Task"}, Updated: updated},
+	}
+	want := "BAR-12    バグ  ログインを直す  -  2020-01-02\n" +
+		"BAR-3     Task  Add the list    -  2020-01-02\n"
+	var buf bytes.Buffer
+	if err := PrintIssues(&buf, issues, &ListFormat{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("PrintIssues() = %q, want %q", got, want)
+	}
+}
+
+func TestBacklogRepository_CreateIssue(t *testing.T) {
+	responses := map[string]interface{}{
+		"GET /api/v2/projects/BAR": &backlog.Project{ID: 5, ProjectKey: "BAR"},
+		"GET /api/v2/users/myself": &backlog.User{ID: 1, UserID: "alice", Name: "Alice"},
+		"GET /api/v2/projects/BAR/issueTypes": []*backlog.IssueType{
+			{ID: 40, Name: "Task"},
+			{ID: 41, Name: "Bug"},
+		},
+		"GET /api/v2/priorities": []*backlog.Priority{
+			{ID: 2, Name: "High"},
+			{ID: 3, Name: "Normal"},
+			{ID: 4, Name: "Low"},
+		},
+		"GET /api/v2/projects/BAR/versions":   []*backlog.Version{{ID: 20, Name: "v1.0"}},
+		"GET /api/v2/projects/BAR/categories": []*backlog.Category{{ID: 30, Name: "Frontend"}},
+		"GET /api/v2/issues/BAR-1":            &backlog.Issue{ID: 100, IssueKey: "BAR-1"},
+		"POST /api/v2/issues":                 &backlog.Issue{ID: 101, IssueKey: "BAR-2"},
+	}
+	tests := []struct {
+		name     string
+		opt      *CreateIssueOptions
+		want     string
+		wantForm url.Values
+		wantErr  bool
+	}{
+		{
+			name: "defaults",
+			opt:  &CreateIssueOptions{Title: "Fix the login"},
+			want: "BAR-2",
+			wantForm: url.Values{
+				"projectId":   {"5"},
+				"summary":     {"Fix the login"},
+				"issueTypeId": {"40"},
+				"priorityId":  {"3"},
+			},
+		},
+		{
+			name: "all fields",
+			opt: &CreateIssueOptions{
+				Title:     "Fix the login",
+				Body:      "It fails.",
+				Type:      "Bug",
+				Priority:  "high",
+				Assignee:  "me",
+				Milestone: "v1.0",
+				Category:  "Frontend",
+				Due:       "2020-01-31",
+				Parent:    "BAR-1",
+			},
+			want: "BAR-2",
+			wantForm: url.Values{
+				"projectId":     {"5"},
+				"summary":       {"Fix the login"},
+				"description":   {"It fails."},
+				"issueTypeId":   {"41"},
+				"priorityId":    {"2"},
+				"assigneeId":    {"1"},
+				"milestoneId[]": {"20"},
+				"categoryId[]":  {"30"},
+				"dueDate":       {"2020-01-31"},
+				"parentIssueId": {"100"},
+			},
+		},
+		{
+			name:    "no title",
+			opt:     &CreateIssueOptions{},
+			wantErr: true,
+		},
+		{
+			name:    "unknown type",
+			opt:     &CreateIssueOptions{Title: "Fix", Type: "Story"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid due date",
+			opt:     &CreateIssueOptions{Title: "Fix", Due: "2020/01/31"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forms := make(map[string]url.Values)
+			b := &BacklogRepository{
+				api:        newAPIServer(t, responses, forms),
+				domain:     "backlog.com",
+				spaceKey:   "foo",
+				projectKey: "BAR",
+				repoName:   "baz",
+			}
+			key, u, err := b.CreateIssue(tt.opt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BacklogRepository.CreateIssue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if _, ok := forms["POST /api/v2/issues"]; ok {
+					t.Error("BacklogRepository.CreateIssue() added an issue on error")
+				}
+				return
+			}
+			if key != tt.want {
+				t.Errorf("BacklogRepository.CreateIssue() key = %v, want %v", key, tt.want)
+			}
+			if wantURL := "https://foo.backlog.com/view/" + tt.want; u != wantURL {
+				t.Errorf("BacklogRepository.CreateIssue() url = %v, want %v", u, wantURL)
+			}
+			if got := forms["POST /api/v2/issues"]; !reflect.DeepEqual(got, tt.wantForm) {
+				t.Errorf("BacklogRepository.CreateIssue() form = %v, want %v", got, tt.wantForm)
+			}
+		})
+	}
+}
+
+func Test_parseIssueTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    *CreateIssueOptions
+		wantErr bool
+	}{
+		{
+			name: "round trip",
+			text: renderIssueTemplate(&CreateIssueOptions{
+				Title:    "Fix the login",
+				Body:     "## Steps\n\n1. Log in\n---\nIt fails.",
+				Priority: "High",
+			}),
+			want: &CreateIssueOptions{
+				Title:    "Fix the login",
+				Body:     "## Steps\n\n1. Log in\n---\nIt fails.",
+				Priority: "High",
+			},
+		},
+		{
+			name: "edited",
+			text: "---\ntitle: Add the list \ntype: Task\nassignee: me\ndue: 2020-01-31\n---\n\nThe list of issues.\n",
+			want: &CreateIssueOptions{
+				Title:    "Add the list",
+				Body:     "The list of issues.",
+				Type:     "Task",
+				Assignee: "me",
+				Due:      "2020-01-31",
+			},
+		},
+		{
+			name: "no front matter",
+			text: "Only the description\n",
+			want: &CreateIssueOptions{Body: "Only the description"},
+		},
+		{
+			name:    "unknown field",
+			text:    "---\nlabel: bug\n---\n",
+			wantErr: true,
+		},
+		{
+			name:    "not closed",
+			text:    "---\ntitle: Fix\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := &CreateIssueOptions{}
+			err := parseIssueTemplate(tt.text, got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseIssueTemplate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseIssueTemplate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_readBodyFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "body.md")
+	if err := ioutil.WriteFile(file, []byte("Fixed.\n\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readBodyFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Fixed." {
+		t.Errorf("readBodyFile() = %q, want %q", got, "Fixed.")
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	defer func(stdin *os.File) { os.Stdin = stdin }(os.Stdin)
+	os.Stdin = f
+	if got, err = readBodyFile("-"); err != nil {
+		t.Fatal(err)
+	}
+	if got != "Fixed." {
+		t.Errorf("readBodyFile(\"-\") = %q, want %q", got, "Fixed.")
+	}
+
+	if _, err := readBodyFile(filepath.Join(dir, "none.md")); err == nil {
+		t.Error("readBodyFile() error = nil, want an error of the missing file")
+	}
+}
+
+func Test_issueBranchName(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		issue    *backlog.Issue
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "default",
+			template: "feature/{key}-{slug}",
+			issue:    &backlog.Issue{IssueKey: "BAR-12", Summary: "Fix the login (Safari)"},
+			want:     "feature/BAR-12-fix-the-login-safari",
+		},
+		{
+			name:     "empty slug",
+			template: "feature/{key}-{slug}",
+			issue:    &backlog.Issue{IssueKey: "BAR_APP-3", Summary: "ログインを直す"},
+			want:     "feature/BAR_APP-3",
+		},
+		{
+			name:     "slug first",
+			template: "{slug}/{key}",
+			issue:    &backlog.Issue{IssueKey: "BAR-12", Summary: "Fix"},
+			want:     "fix/BAR-12",
+		},
+		{
+			name:     "no key",
+			template: "feature/{slug}",
+			issue:    &backlog.Issue{IssueKey: "BAR-12", Summary: "Fix"},
+			wantErr:  true,
+		},
+		{
+			name:     "another key first",
+			template: "FOO-1/{key}",
+			issue:    &backlog.Issue{IssueKey: "BAR-12", Summary: "Fix"},
+			wantErr:  true,
+		},
+		{
+			name:     "invalid",
+			template: "feature/{key}..{slug}",
+			issue:    &backlog.Issue{IssueKey: "BAR-12", Summary: "Fix"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &BacklogRepository{repo: &RepositoryMock{GitFunc: workingDirGit}}
+			got, err := b.issueBranchName(tt.template, tt.issue)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BacklogRepository.issueBranchName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("BacklogRepository.issueBranchName() = %v, want %v", got, tt.want)
+			}
+			if err == nil && extractIssueKey(got) != tt.issue.IssueKey {
+				t.Errorf("extractIssueKey(%q) = %v, want %v", got, extractIssueKey(got), tt.issue.IssueKey)
+			}
+		})
+	}
+}
+
+func TestBacklogRepository_StartIssue(t *testing.T) {
+	responses := map[string]interface{}{
+		"GET /api/v2/issues/BAR-1":   &backlog.Issue{ID: 100, IssueKey: "BAR-1", Summary: "Fix the login"},
+		"GET /api/v2/users/myself":   &backlog.User{ID: 7, Name: "Alice"},
+		"PATCH /api/v2/issues/BAR-1": &backlog.Issue{ID: 100, IssueKey: "BAR-1"},
+	}
+	forms := make(map[string]url.Values)
+	var calls [][]string
+	var fetched []string
+	b := &BacklogRepository{
+		repo: &RepositoryMock{
+			RemoteNameFunc: func() string {
+				return "origin"
+			},
+			DefaultBranchFunc: func() (string, error) {
+				return "master", nil
+			},
+			FetchFunc: func(refs ...string) error {
+				fetched = refs
+				return nil
+			},
+			// The branch does not exist yet.
+			GitFunc: fakeGitCommand(&calls, func(args []string) bool {
+				return args[0] == "rev-parse"
+			}),
+		},
+		api:        newAPIServer(t, responses, forms),
+		projectKey: "BAR",
+	}
+	got, err := b.StartIssue("BAR-1", &StartIssueOptions{Branch: "feature/{key}-{slug}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "feature/BAR-1-fix-the-login"; got != want {
+		t.Errorf("BacklogRepository.StartIssue() = %v, want %v", got, want)
+	}
+	if want := []string{"master"}; !reflect.DeepEqual(fetched, want) {
+		t.Errorf("BacklogRepository.StartIssue() fetched %v, want %v", fetched, want)
+	}
+	wantCalls := [][]string{
+		{"check-ref-format", "--branch", "feature/BAR-1-fix-the-login"},
+		{"rev-parse", "--verify", "--quiet", "refs/heads/feature/BAR-1-fix-the-login"},
+		{"checkout", "--no-track", "-b", "feature/BAR-1-fix-the-login", "refs/remotes/origin/master"},
+	}
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("BacklogRepository.StartIssue() ran git %v, want %v", calls, wantCalls)
+	}
+	wantForm := url.Values{"statusId": {"2"}, "assigneeId": {"7"}}
+	if f := forms["PATCH /api/v2/issues/BAR-1"]; !reflect.DeepEqual(f, wantForm) {
+		t.Errorf("BacklogRepository.StartIssue() updated the issue with %v, want %v", f, wantForm)
+	}
+}
+
+func Test_slugify(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"Fix the login", "fix-the-login"},
+		{"  Add API v2 -- endpoints! ", "add-api-v2-endpoints"},
+		{"ログイン画面のFix", "fix"},
+		{"Improve the performance of the issue list and the pull request list", "improve-the-performance-of-the-issue"},
+		{"abcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyz", "abcdefghijklmnopqrstuvwxyzabcdefghijklmn"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			if got := slugify(tt.s); got != tt.want {
+				t.Errorf("slugify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBacklogRepository_ViewIssue(t *testing.T) {
+	comment := func(id int, content string) *backlog.Comment {
+		return &backlog.Comment{ID: id, Content: content, CreatedUser: &backlog.User{Name: "Alice"}}
+	}
+	responses := map[string]interface{}{
+		"GET /api/v2/issues/BAR-1": &backlog.Issue{ID: 100, ProjectID: 5, IssueKey: "BAR-1", Summary: "Fix the login"},
+		"GET /api/v2/projects/5":   &backlog.Project{ID: 5, ProjectKey: "BAR", TextFormattingRule: "backlog"},
+		// Newest first, as the API returns them by default.
+		"GET /api/v2/issues/BAR-1/comments": []*backlog.Comment{
+			comment(4, "Fixed."),
+			comment(3, ""),
+			comment(2, "Reproduced."),
+			comment(1, "Which browser?"),
+		},
+		"GET /api/v2/projects/BAR/git/repositories/baz/pullRequests": []*backlog.PullRequest{{Number: 3}},
+	}
+	forms := make(map[string]url.Values)
+	b := &BacklogRepository{
+		repo: &RepositoryMock{
+			HeadShortNameFunc: func() string {
+				return "feature/BAR-1-fix-the-login"
+			},
+		},
+		api:        newAPIServer(t, responses, forms),
+		domain:     "backlog.com",
+		spaceKey:   "foo",
+		projectKey: "BAR",
+		repoName:   "baz",
+	}
+	got, err := b.ViewIssue("", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Issue.IssueKey != "BAR-1" {
+		t.Errorf("BacklogRepository.ViewIssue() issue = %v, want BAR-1", got.Issue.IssueKey)
+	}
+	if got.URL != "https://foo.backlog.com/view/BAR-1" {
+		t.Errorf("BacklogRepository.ViewIssue() url = %v", got.URL)
+	}
+	if got.TextFormattingRule != "backlog" {
+		t.Errorf("BacklogRepository.ViewIssue() text formatting rule = %v, want backlog", got.TextFormattingRule)
+	}
+	var gotComments []string
+	for _, c := range got.Comments {
+		gotComments = append(gotComments, c.Content)
+	}
+	if want := []string{"Reproduced.", "Fixed."}; !reflect.DeepEqual(gotComments, want) {
+		t.Errorf("BacklogRepository.ViewIssue() comments = %v, want %v", gotComments, want)
+	}
+	if len(got.PullRequests) != 1 || got.PullRequests[0].Number != 3 {
+		t.Errorf("BacklogRepository.ViewIssue() pull requests = %v", got.PullRequests)
+	}
+	wantQuery := url.Values{"issueId[]": {"100"}, "offset": {"0"}, "count": {"100"}}
+	if q := forms["GET /api/v2/projects/BAR/git/repositories/baz/pullRequests"]; !reflect.DeepEqual(q, wantQuery) {
+		t.Errorf("BacklogRepository.ViewIssue() pull requests query = %v, want %v", q, wantQuery)
+	}
+}
+
+func TestBacklogRepository_findIssueKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		branch   string
+		messages []string
+		want     string
+		wantErr  bool
+	}{
+		{name: "given", key: "BAR-1", branch: "feature/BAR-2", want: "BAR-1"},
+		{name: "branch", branch: "feature/BAR-2", messages: []string{"Fix BAR-3"}, want: "BAR-2"},
+		{name: "commit message", branch: "master", messages: []string{"Fix BAR-3", "Use UTF-8\n\nSee FOO-4"}, want: "BAR-3"},
+		{name: "no key of the project", branch: "master", messages: []string{"Support SHA-256", "Use UTF-8"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, commit := newGitRepo(t)
+			for i, m := range tt.messages {
+				commit("a.txt", strconv.Itoa(i), m)
+			}
+			b := &BacklogRepository{
+				repo: &RepositoryMock{
+					HeadShortNameFunc: func() string {
+						return tt.branch
+					},
+					GitFunc: workingDirGit,
+				},
+				projectKey: "BAR",
+			}
+			got, err := b.findIssueKey(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BacklogRepository.findIssueKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("BacklogRepository.findIssueKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintIssueView(t *testing.T) {
+	due := time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC)
+	v := &IssueView{
+		Issue: &backlog.Issue{
+			IssueKey:    "BAR-1",
+			Summary:     "Fix the login",
+			IssueType:   &backlog.IssueType{Name: "Bug"},
+			Status:      &backlog.Status{ID: 2, Name: "In Progress"},
+			Priority:    &backlog.Priority{Name: "High"},
+			DueDate:     &due,
+			Description: "* Steps\n-Open the page\n-''Log in''",
+		},
+		URL:                "https://foo.backlog.com/view/BAR-1",
+		TextFormattingRule: "backlog",
+		Comments: []*backlog.Comment{
+			{
+				Content:     "Reproduced.\nOn Safari.",
+				CreatedUser: &backlog.User{Name: "Alice"},
+				Created:     time.Date(2020, 1, 2, 15, 4, 0, 0, time.Local),
+			},
+		},
+		PullRequests: []*backlog.PullRequest{
+			{Number: 3, Summary: "Fix the login", Branch: "feature/BAR-1", Base: "master", Status: &backlog.Status{ID: 1, Name: "Open"}},
+			{Number: 12, Summary: "ログインを直す", Branch: "feature/BAR-1-2", Base: "master", Status: &backlog.Status{ID: 3, Name: "Merged"}},
+		},
+	}
+	want := `Fix the login BAR-1
+In Progress • Bug • Priority: High • Assignee: - • Due: 2020-01-31
+https://foo.backlog.com/view/BAR-1
+
+  # Steps
+  - Open the page
+  - Log in
+
+Pull requests
+  #3   Open    Fix the login   feature/BAR-1 -> master
+  #12  Merged  ログインを直す  feature/BAR-1-2 -> master
+
+Recent comments
+  Alice 2020-01-02 15:04
+    Reproduced.
+    On Safari.
+`
+	var buf bytes.Buffer
+	if err := PrintIssueView(&buf, v, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("PrintIssueView() = %q, want %q", got, want)
+	}
+}
@@ -1,14 +1,20 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/url"
 	"os"
 	"os/exec"
 	"path"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/urfave/cli"
+	"github.com/vvatanabe/gitb/internal/backlog"
 )
 
 func help() string {
@@ -23,8 +29,16 @@ These Backlog's git commands are provided by gitb:
      pr       Open the pull request list page in current repository
      issue    Open the issue list page in current project
      browse   Open other git page (e.g. branch, tree, tag, and more...) in current repository
+     config   Get and set gitb's options
+     auth     Log in to Backlog's spaces for the commands using Backlog API
      help, h  Shows a list of commands or help for one command
 
+These options are provided by gitb:
+     --remote <name>  Use the given remote as Backlog's repository
+                      (default: gitb config "remote", or the remote detected as Backlog)
+     --print          Print URLs to stdout instead of opening the browser
+     --copy           Copy URLs to the clipboard instead of opening the browser
+
 `)
 	return sb.String()
 }
@@ -36,33 +50,60 @@ func main() {
 	app.UsageText = usageText
 	app.CustomAppHelpTemplate = help()
 	app.Version = FmtVersion()
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "remote",
+			Usage: "Use the given remote as Backlog's repository",
+		},
+		cli.BoolFlag{
+			Name:  "print",
+			Usage: "Print URLs to stdout instead of opening the browser",
+		},
+		cli.BoolFlag{
+			Name:  "copy",
+			Usage: "Copy URLs to the clipboard instead of opening the browser",
+		},
+	}
 	app.Commands = []cli.Command{
 		{
 			Name:  "pr",
 			Usage: "Open the pull request list page in current repository",
 			Flags: []cli.Flag{
 				cli.StringFlag{
-					Name:  "s, state",
-					Value: "open",
+					Name: "s, state",
 				},
 			},
 			Action: func(c *cli.Context) error {
-				s := c.String("state")
-				repo, err := open(".")
+				repo, err := open(c)
 				if err != nil {
 					return exit(err)
 				}
+				s := stringOrConfig(c, "state", repo.config, "pr.state")
 				return exit(repo.OpenPullRequestList(s))
 			},
 			Subcommands: []cli.Command{
 				{
 					Name:  "show",
 					Usage: "Open the pull request page. When no specify <PR-ID>, open the PR page related to the current branch",
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "all",
+							Usage: "List all pull requests related to the current branch instead",
+						},
+						cli.BoolFlag{
+							Name:  "online",
+							Usage: "Look up the PR in the remote instead of the refs fetched by gitb pr fetch",
+						},
+					},
 					Action: func(c *cli.Context) error {
-						repo, err := open(".")
+						repo, err := open(c)
 						if err != nil {
 							return exit(err)
 						}
+						repo.online = c.Bool("online")
+						if c.Bool("all") {
+							return exit(repo.PrintPullRequestsOfBranch(os.Stdout))
+						}
 						if c.Args().Present() {
 							return exit(repo.OpenPullRequestByID(c.Args().First()))
 						}
@@ -78,25 +119,315 @@ func main() {
 						},
 					},
 					Action: func(c *cli.Context) error {
-						base := c.String("base")
-						repo, err := open(".")
+						repo, err := open(c)
 						if err != nil {
 							return exit(err)
 						}
+						base := stringOrConfig(c, "base", repo.config, "pr.base")
 						return exit(repo.OpenAddPullRequest(base, ""))
 					},
 				},
+				{
+					Name:  "list",
+					Usage: "List pull requests in current repository with Backlog API",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "s, state",
+							Usage: "Filter by state: open, closed, merged, all (default: gitb config \"pr.state\")",
+						},
+						cli.StringFlag{
+							Name:  "author",
+							Usage: "Filter by the user ID or name of the author",
+						},
+						cli.StringFlag{
+							Name:  "a, assignee",
+							Usage: "Filter by the user ID or name of the assignee",
+						},
+						cli.StringFlag{
+							Name:  "i, issue",
+							Usage: "Filter by the linked issue key",
+						},
+						cli.StringFlag{
+							Name:  "b, base",
+							Usage: "Filter by the base branch",
+						},
+						cli.IntFlag{
+							Name:  "L, limit",
+							Value: 30,
+							Usage: "Maximum number of pull requests. 0 means all",
+						},
+						cli.BoolFlag{
+							Name:  "json",
+							Usage: "Print as JSON",
+						},
+						cli.StringFlag{
+							Name:  "format",
+							Usage: "Print each pull request with the Go template. e.g. '{{.Number}} {{.Summary}}'",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						repo, err := open(c)
+						if err != nil {
+							return exit(err)
+						}
+						prs, err := repo.ListPullRequests(&ListPullRequestsOptions{
+							State:    stringOrConfig(c, "state", repo.config, "pr.state"),
+							Author:   c.String("author"),
+							Assignee: c.String("assignee"),
+							Issue:    c.String("issue"),
+							Base:     c.String("base"),
+							Limit:    c.Int("limit"),
+						})
+						if err != nil {
+							return exit(err)
+						}
+						return exit(PrintPullRequests(os.Stdout, prs, &ListFormat{
+							JSON:     c.Bool("json"),
+							Template: c.String("format"),
+							Color:    useColor(os.Stdout),
+						}))
+					},
+				},
+				{
+					Name:      "checkout",
+					Usage:     "Check out the pull request into a local branch tracking it",
+					ArgsUsage: "<PR-ID>",
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "worktree",
+							Usage: "Check out into a new worktree instead of the current one",
+						},
+						cli.StringFlag{
+							Name:  "path",
+							Usage: "Path of the worktree (default: <repo>-pr-<PR-ID> beside the repository)",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						if !c.Args().Present() {
+							return exit(errors.New("PR-ID is required"))
+						}
+						repo, err := open(c)
+						if err != nil {
+							return exit(err)
+						}
+						return exit(repo.CheckoutPullRequest(c.Args().First(), &CheckoutPullRequestOptions{
+							Worktree: c.Bool("worktree") || c.String("path") != "",
+							Path:     c.String("path"),
+						}))
+					},
+				},
+				{
+					Name:      "diff",
+					Usage:     "Show the diff of the pull request. When no specify <PR-ID>, show the PR related to the current branch",
+					ArgsUsage: "[<PR-ID>] [git diff options]",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "b, base",
+							Usage: "Base branch (default: the base branch of the PR, or the default branch of the remote)",
+						},
+						cli.BoolFlag{
+							Name:  "online",
+							Usage: "Look up the PR in the remote instead of the refs fetched by gitb pr fetch",
+						},
+					},
+					// Flags are parsed by prDiffFlags, so that the options of
+					// git diff can be given before "--".
+					SkipFlagParsing: true,
+					Action: func(c *cli.Context) error {
+						base, online, help, args, err := prDiffFlags(c.Args())
+						if err != nil {
+							return exit(err)
+						}
+						if help {
+							return cli.ShowCommandHelp(c, c.Command.Name)
+						}
+						repo, err := open(c)
+						if err != nil {
+							return exit(err)
+						}
+						repo.online = online
+						id, args := prIDAndArgs(args)
+						return exit(repo.DiffPullRequest(id, base, false, args))
+					},
+				},
+				{
+					Name:      "files",
+					Usage:     "Show the files changed by the pull request. When no specify <PR-ID>, show the PR related to the current branch",
+					ArgsUsage: "[<PR-ID>] [git diff options]",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "b, base",
+							Usage: "Base branch (default: the base branch of the PR, or the default branch of the remote)",
+						},
+						cli.BoolFlag{
+							Name:  "online",
+							Usage: "Look up the PR in the remote instead of the refs fetched by gitb pr fetch",
+						},
+					},
+					// Flags are parsed by prDiffFlags, so that the options of
+					// git diff can be given before "--".
+					SkipFlagParsing: true,
+					Action: func(c *cli.Context) error {
+						base, online, help, args, err := prDiffFlags(c.Args())
+						if err != nil {
+							return exit(err)
+						}
+						if help {
+							return cli.ShowCommandHelp(c, c.Command.Name)
+						}
+						repo, err := open(c)
+						if err != nil {
+							return exit(err)
+						}
+						repo.online = online
+						id, args := prIDAndArgs(args)
+						return exit(repo.DiffPullRequest(id, base, true, args))
+					},
+				},
+				{
+					Name:  "fetch",
+					Usage: "Fetch pull requests into refs/remotes/<remote>/pr/<PR-ID> to look them up without asking the remote",
+					Action: func(c *cli.Context) error {
+						repo, err := open(c)
+						if err != nil {
+							return exit(err)
+						}
+						return exit(repo.FetchPullRequests())
+					},
+				},
+				{
+					Name:  "create",
+					Usage: "Create a pull request of current branch with Backlog API, and print its URL",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "b, base",
+							Usage: "Base branch (default: gitb config \"pr.base\", or the default branch of the remote)",
+						},
+						cli.StringFlag{
+							Name:  "t, title",
+							Usage: "Title (default: the subject of the first commit)",
+						},
+						cli.StringFlag{
+							Name:  "body",
+							Usage: "Description (default: the commit log since the merge-base)",
+						},
+						cli.StringFlag{
+							Name:  "i, issue",
+							Usage: "Issue key to link (default: the issue key in the branch name)",
+						},
+						cli.StringFlag{
+							Name:  "a, assignee",
+							Usage: "User ID or name of the assignee",
+						},
+						cli.StringSliceFlag{
+							Name:  "notify",
+							Usage: "User ID or name to notify. Can be repeated",
+						},
+						cli.BoolFlag{
+							Name:  "push",
+							Usage: "Push current branch when it is not pushed yet",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						repo, err := open(c)
+						if err != nil {
+							return exit(err)
+						}
+						u, err := repo.CreatePullRequest(&CreatePullRequestOptions{
+							Base:     stringOrConfig(c, "base", repo.config, "pr.base"),
+							Title:    c.String("title"),
+							Body:     c.String("body"),
+							Issue:    c.String("issue"),
+							Assignee: c.String("assignee"),
+							Notify:   c.StringSlice("notify"),
+							Push:     c.Bool("push"),
+						})
+						if err != nil {
+							return exit(err)
+						}
+						fmt.Println(u)
+						return nil
+					},
+				},
+				{
+					Name:      "comment",
+					Usage:     "Comment on the pull request, or the one of current branch, with Backlog API",
+					ArgsUsage: "[PR-ID]",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "m, message",
+							Usage: "Comment. It is written in the editor on a terminal when omitted",
+						},
+						cli.StringFlag{
+							Name:  "F, message-file",
+							Usage: "Read the comment from the file. \"-\" reads stdin",
+						},
+						cli.StringSliceFlag{
+							Name:  "notify",
+							Usage: "User ID or name to notify. Can be repeated",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						repo, err := open(c)
+						if err != nil {
+							return exit(err)
+						}
+						if _, err := repo.APIClient(); err != nil {
+							return exit(err)
+						}
+						body, err := commentMessage(c)
+						if err != nil {
+							return exit(err)
+						}
+						u, err := repo.CommentPullRequest(c.Args().First(), &CommentOptions{
+							Body:   body,
+							Notify: c.StringSlice("notify"),
+						})
+						if err != nil {
+							return exit(err)
+						}
+						fmt.Println(u)
+						return nil
+					},
+				},
+				{
+					Name:      "for-commit",
+					Usage:     "Open the pull request which brought the commit into the default branch",
+					ArgsUsage: "<COMMIT>",
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "all-branches",
+							Usage: "List every remote branch the commit landed on, with the pull request which brought it",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						if !c.Args().Present() {
+							return exit(errors.New("COMMIT is required"))
+						}
+						repo, err := open(c)
+						if err != nil {
+							return exit(err)
+						}
+						if c.Bool("all-branches") {
+							return exit(repo.PrintCommitLandings(os.Stdout, c.Args().First()))
+						}
+						return exit(repo.OpenPullRequestForCommit(c.Args().First()))
+					},
+				},
 				{
 					Name:            "blame",
 					Usage:           "Show pull request id with git blame",
+					ArgsUsage:       "[--json | --porcelain] [git blame command options] <PATH>",
 					SkipFlagParsing: true,
 					Action: func(c *cli.Context) error {
-						repo, err := open(".")
+						repo, err := open(c)
 						if err != nil {
 							return exit(err)
 						}
-						if c.Args().Present() {
-							return exit(repo.BlamePR(c.Args()))
+						f, args := blameFormatAndArgs(c.Args())
+						if len(args) > 0 {
+							f.Color = !f.JSON && !f.Porcelain && useColor(os.Stdout)
+							return exit(repo.BlamePR(args, f))
 						}
 						return exit(nil)
 					},
@@ -108,41 +439,338 @@ func main() {
 			Usage: "Open the issue list page in current project",
 			Flags: []cli.Flag{
 				cli.StringFlag{
-					Name:  "s, state",
-					Value: "not_closed",
+					Name: "s, state",
 				},
 			},
 			Action: func(c *cli.Context) error {
-				s := c.String("state")
-				repo, err := open(".")
+				repo, err := open(c)
 				if err != nil {
 					return exit(err)
 				}
+				s := stringOrConfig(c, "state", repo.config, "issue.state")
 				return exit(repo.OpenIssueList(s))
 			},
 			Subcommands: []cli.Command{
+				{
+					Name:  "list",
+					Usage: "List issues in current project with Backlog API",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "s, state",
+							Usage: "Filter by state: open, in_progress, resolved, closed, not_closed, all, or a custom status (default: gitb config \"issue.state\")",
+						},
+						cli.StringFlag{
+							Name:  "a, assignee",
+							Usage: "Filter by the user ID or name of the assignee. \"me\" is you",
+						},
+						cli.StringFlag{
+							Name:  "m, milestone",
+							Usage: "Filter by the name of the milestone",
+						},
+						cli.StringFlag{
+							Name:  "c, category",
+							Usage: "Filter by the name of the category",
+						},
+						cli.StringFlag{
+							Name:  "t, type",
+							Usage: "Filter by the name of the issue type",
+						},
+						cli.StringFlag{
+							Name:  "k, keyword",
+							Usage: "Filter by the keyword in the summary, description and comments",
+						},
+						cli.StringFlag{
+							Name:  "sort",
+							Usage: "Sort by the attribute, e.g. updated, created, dueDate, priority (default: updated)",
+						},
+						cli.IntFlag{
+							Name:  "L, limit",
+							Value: 30,
+							Usage: "Maximum number of issues. 0 means all",
+						},
+						cli.BoolFlag{
+							Name:  "json",
+							Usage: "Print as JSON",
+						},
+						cli.StringFlag{
+							Name:  "format",
+							Usage: "Print each issue with the Go template. e.g. '{{.IssueKey}} {{.Summary}}'",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						repo, err := open(c)
+						if err != nil {
+							return exit(err)
+						}
+						issues, err := repo.ListIssues(&ListIssuesOptions{
+							State:     stringOrConfig(c, "state", repo.config, "issue.state"),
+							Assignee:  c.String("assignee"),
+							Milestone: c.String("milestone"),
+							Category:  c.String("category"),
+							Type:      c.String("type"),
+							Keyword:   c.String("keyword"),
+							Sort:      c.String("sort"),
+							Limit:     c.Int("limit"),
+						})
+						if err != nil {
+							return exit(err)
+						}
+						return exit(PrintIssues(os.Stdout, issues, &ListFormat{
+							JSON:     c.Bool("json"),
+							Template: c.String("format"),
+							Color:    useColor(os.Stdout),
+						}))
+					},
+				},
 				{
 					Name:  "show",
 					Usage: "Open the issue page related to current branch",
 					Action: func(c *cli.Context) error {
-						repo, err := open(".")
+						repo, err := open(c)
 						if err != nil {
 							return exit(err)
 						}
 						return exit(repo.OpenIssue())
 					},
 				},
+				{
+					Name:      "view",
+					Usage:     "Show the issue with Backlog API. ISSUE-KEY defaults to the one in current branch name or recent commits",
+					ArgsUsage: "[ISSUE-KEY]",
+					Flags: []cli.Flag{
+						cli.IntFlag{
+							Name:  "c, comments",
+							Value: 5,
+							Usage: "Number of recent comments to show",
+						},
+						cli.BoolFlag{
+							Name:  "w, web",
+							Usage: "Open the issue page in the browser instead",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						repo, err := open(c)
+						if err != nil {
+							return exit(err)
+						}
+						if c.Bool("web") {
+							return exit(repo.OpenIssueByKey(c.Args().First()))
+						}
+						v, err := repo.ViewIssue(c.Args().First(), c.Int("comments"))
+						if err != nil {
+							return exit(err)
+						}
+						return exit(PrintIssueView(os.Stdout, v, useColor(os.Stdout)))
+					},
+				},
 				{
 					Name:  "add",
 					Usage: "Open the page to add issue in current repository's project",
 					Action: func(c *cli.Context) error {
-						repo, err := open(".")
+						repo, err := open(c)
 						if err != nil {
 							return exit(err)
 						}
 						return exit(repo.OpenAddIssue())
 					},
 				},
+				{
+					Name:  "create",
+					Usage: "Create an issue in current project with Backlog API, and print its key and URL",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "t, title",
+							Usage: "Title. The editor is opened when it is omitted on a terminal",
+						},
+						cli.StringFlag{
+							Name:  "body",
+							Usage: "Description",
+						},
+						cli.StringFlag{
+							Name:  "F, body-file",
+							Usage: "Read the description from the file. \"-\" reads stdin",
+						},
+						cli.StringFlag{
+							Name:  "type",
+							Usage: "Name of the issue type (default: gitb config \"issue.type\", or the first type of the project)",
+						},
+						cli.StringFlag{
+							Name:  "priority",
+							Usage: "Name of the priority (default: gitb config \"issue.priority\", or Normal)",
+						},
+						cli.StringFlag{
+							Name:  "a, assignee",
+							Usage: "User ID or name of the assignee, or \"me\"",
+						},
+						cli.StringFlag{
+							Name:  "m, milestone",
+							Usage: "Name of the milestone",
+						},
+						cli.StringFlag{
+							Name:  "c, category",
+							Usage: "Name of the category",
+						},
+						cli.StringFlag{
+							Name:  "due",
+							Usage: "Due date in YYYY-MM-DD",
+						},
+						cli.StringFlag{
+							Name:  "parent",
+							Usage: "Issue key of the parent issue",
+						},
+						cli.BoolFlag{
+							Name:  "e, edit",
+							Usage: "Edit the issue in the editor before creating it",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						repo, err := open(c)
+						if err != nil {
+							return exit(err)
+						}
+						opt := &CreateIssueOptions{
+							Title:     c.String("title"),
+							Body:      c.String("body"),
+							Type:      stringOrConfig(c, "type", repo.config, "issue.type"),
+							Priority:  stringOrConfig(c, "priority", repo.config, "issue.priority"),
+							Assignee:  c.String("assignee"),
+							Milestone: c.String("milestone"),
+							Category:  c.String("category"),
+							Due:       c.String("due"),
+							Parent:    c.String("parent"),
+						}
+						if file := c.String("body-file"); file != "" {
+							if opt.Body, err = readBodyFile(file); err != nil {
+								return exit(err)
+							}
+						}
+						if c.Bool("edit") || opt.Title == "" && isTerminal(os.Stdin) {
+							if err := EditIssue(opt); err != nil {
+								return exit(err)
+							}
+						}
+						key, u, err := repo.CreateIssue(opt)
+						if err != nil {
+							return exit(err)
+						}
+						fmt.Println(key, u)
+						return nil
+					},
+				},
+				{
+					Name:      "start",
+					Usage:     "Check out a new branch for the issue, and set the issue in progress and assigned to you with Backlog API",
+					ArgsUsage: "<ISSUE-KEY>",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "b, base",
+							Usage: "Branch of the remote to start from (default: gitb config \"pr.base\", or the default branch of the remote)",
+						},
+						cli.StringFlag{
+							Name:  "branch",
+							Usage: "Branch name. {key} is the issue key, and {slug} is made of the summary (default: gitb config \"issue.branch\")",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						if c.NArg() == 0 {
+							return exit(errors.New("ISSUE-KEY is required"))
+						}
+						repo, err := open(c)
+						if err != nil {
+							return exit(err)
+						}
+						branch, err := repo.StartIssue(c.Args().First(), &StartIssueOptions{
+							Branch: stringOrConfig(c, "branch", repo.config, "issue.branch"),
+							Base:   stringOrConfig(c, "base", repo.config, "pr.base"),
+						})
+						if err != nil {
+							return exit(err)
+						}
+						fmt.Println(branch)
+						return nil
+					},
+				},
+				{
+					Name:      "comment",
+					Usage:     "Comment on the issue with Backlog API. ISSUE-KEY defaults to the one in current branch name or recent commits",
+					ArgsUsage: "[ISSUE-KEY]",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "m, message",
+							Usage: "Comment. It is written in the editor on a terminal when omitted",
+						},
+						cli.StringFlag{
+							Name:  "F, message-file",
+							Usage: "Read the comment from the file. \"-\" reads stdin",
+						},
+						cli.StringSliceFlag{
+							Name:  "notify",
+							Usage: "User ID or name to notify. Can be repeated",
+						},
+						cli.StringSliceFlag{
+							Name:  "attach",
+							Usage: "File to attach. Can be repeated",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						repo, err := open(c)
+						if err != nil {
+							return exit(err)
+						}
+						if _, err := repo.APIClient(); err != nil {
+							return exit(err)
+						}
+						body, err := commentMessage(c)
+						if err != nil {
+							return exit(err)
+						}
+						u, err := repo.CommentIssue(c.Args().First(), &CommentOptions{
+							Body:   body,
+							Notify: c.StringSlice("notify"),
+							Attach: c.StringSlice("attach"),
+						})
+						if err != nil {
+							return exit(err)
+						}
+						fmt.Println(u)
+						return nil
+					},
+				},
+				{
+					Name:            "blame",
+					Usage:           "Show issue key with git blame",
+					ArgsUsage:       "[--summary] [--json | --porcelain] [git blame command options] <PATH>",
+					SkipFlagParsing: true,
+					Action: func(c *cli.Context) error {
+						repo, err := open(c)
+						if err != nil {
+							return exit(err)
+						}
+						f, args := blameFormatAndArgs(c.Args())
+						if len(args) > 0 {
+							f.Color = !f.JSON && !f.Porcelain && useColor(os.Stdout)
+							return exit(repo.BlameIssue(args, f))
+						}
+						return exit(nil)
+					},
+				},
+			},
+		},
+		{
+			Name:            "bisect-pr",
+			Usage:           "Find the pull request which introduced a bug by git bisect on the merges of pull requests",
+			ArgsUsage:       "<good> <bad> -- <test command>",
+			SkipFlagParsing: true,
+			Action: func(c *cli.Context) error {
+				good, bad, command, err := bisectArgs(c.Args())
+				if err != nil {
+					return exit(err)
+				}
+				repo, err := open(c)
+				if err != nil {
+					return exit(err)
+				}
+				return exit(repo.BisectPR(os.Stdout, good, bad, command))
 			},
 		},
 		{
@@ -153,7 +781,7 @@ func main() {
 					Name:  "branch",
 					Usage: "Open the branch list page in current repository",
 					Action: func(c *cli.Context) error {
-						repo, err := open(".")
+						repo, err := open(c)
 						if err != nil {
 							return exit(err)
 						}
@@ -164,7 +792,7 @@ func main() {
 					Name:  "tag",
 					Usage: "Open the tag list page in the current repository.",
 					Action: func(c *cli.Context) error {
-						repo, err := open(".")
+						repo, err := open(c)
 						if err != nil {
 							return exit(err)
 						}
@@ -175,7 +803,7 @@ func main() {
 					Name:  "tree",
 					Usage: "Open the tree page in current branch",
 					Action: func(c *cli.Context) error {
-						repo, err := open(".")
+						repo, err := open(c)
 						if err != nil {
 							return exit(err)
 						}
@@ -186,7 +814,7 @@ func main() {
 					Name:  "history",
 					Usage: "Open the history page in current branch",
 					Action: func(c *cli.Context) error {
-						repo, err := open(".")
+						repo, err := open(c)
 						if err != nil {
 							return exit(err)
 						}
@@ -197,7 +825,7 @@ func main() {
 					Name:  "network",
 					Usage: "Open the network page in current branch",
 					Action: func(c *cli.Context) error {
-						repo, err := open(".")
+						repo, err := open(c)
 						if err != nil {
 							return exit(err)
 						}
@@ -208,7 +836,7 @@ func main() {
 					Name:  "repo",
 					Usage: "Open the repository list page in current project",
 					Action: func(c *cli.Context) error {
-						repo, err := open(".")
+						repo, err := open(c)
 						if err != nil {
 							return exit(err)
 						}
@@ -224,7 +852,7 @@ func main() {
 						},
 					},
 					Action: func(c *cli.Context) error {
-						repo, err := open(".")
+						repo, err := open(c)
 						if err != nil {
 							return exit(err)
 						}
@@ -255,7 +883,7 @@ func main() {
 					Name:  "commit",
 					Usage: "Open the network page in current branch",
 					Action: func(c *cli.Context) error {
-						repo, err := open(".")
+						repo, err := open(c)
 						if err != nil {
 							return exit(err)
 						}
@@ -268,6 +896,169 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:  "config",
+			Usage: "Get and set gitb's options",
+			Subcommands: []cli.Command{
+				{
+					Name:      "get",
+					Usage:     "Print the value of the given key",
+					ArgsUsage: "<key>",
+					Action: func(c *cli.Context) error {
+						if !c.Args().Present() {
+							return exit(errors.New("key is required"))
+						}
+						cfg, err := LoadConfig(".")
+						if err != nil {
+							return exit(err)
+						}
+						v, _, ok := cfg.Lookup(c.Args().First())
+						if !ok {
+							return cli.NewExitError("", 1)
+						}
+						fmt.Println(v)
+						return nil
+					},
+				},
+				{
+					Name:      "set",
+					Usage:     "Set the value of the given key in git config of the current repository",
+					ArgsUsage: "<key> <value>",
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "global",
+							Usage: "Write to the user config file instead",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						if c.NArg() != 2 {
+							return exit(errors.New("key and value are required"))
+						}
+						return exit(SetConfig(".", c.Args().Get(0), c.Args().Get(1), c.Bool("global")))
+					},
+				},
+				{
+					Name:  "list",
+					Usage: "List all effective values",
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "show-origin",
+							Usage: "Show the source of each value",
+						},
+						cli.BoolFlag{
+							Name:  "all",
+							Usage: "List the known keys with their descriptions",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						if c.Bool("all") {
+							for _, k := range configKeys {
+								fmt.Printf("%-18s %s\n", k.name, k.usage)
+							}
+							fmt.Printf("%-18s %s\n", "<host>.baseurl", "Web base URL of Backlog for the remote host")
+							fmt.Printf("%-18s %s\n", "<host>.space", "Space key for the remote host")
+							fmt.Printf("%-18s %s\n", "<host>.pathprefix", "Prefix of the remote path before the project key")
+							return nil
+						}
+						cfg, err := LoadConfig(".")
+						if err != nil {
+							return exit(err)
+						}
+						for _, e := range cfg.List() {
+							if c.Bool("show-origin") {
+								fmt.Printf("%s\t", e.Source)
+							}
+							fmt.Printf("%s=%s\n", e.Key, e.Value)
+						}
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:  "auth",
+			Usage: "Log in to Backlog's spaces for the commands using Backlog API",
+			Subcommands: []cli.Command{
+				{
+					Name:  "login",
+					Usage: "Log in to the space of current repository with an API key, or with OAuth 2.0",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "url",
+							Usage: "Base URL of the space instead of the one of current repository",
+						},
+						cli.BoolFlag{
+							Name:  "oauth",
+							Usage: "Authorize with OAuth 2.0 in the browser instead of an API key",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						baseURL, cfg, sink, err := spaceURL(c)
+						if err != nil {
+							return exit(err)
+						}
+						ctx := context.Background()
+						client := backlog.NewClient(baseURL)
+						cred := &Credential{}
+						if c.Bool("oauth") {
+							t, err := AuthorizeOAuth(ctx, client, oauthConfig(cfg), sink)
+							if err != nil {
+								return exit(err)
+							}
+							cred.AccessToken = t.AccessToken
+							cred.RefreshToken = t.RefreshToken
+							cred.Expiry = t.Expiry(time.Now())
+						} else {
+							cred.APIKey, err = ReadAPIKey(os.Stdin, os.Stderr, baseURL)
+							if err != nil {
+								return exit(err)
+							}
+						}
+						me, err := Login(ctx, client, cred, credentialsFile())
+						if err != nil {
+							return exit(err)
+						}
+						fmt.Printf("Logged in to %s as %s (%s)\n", baseURL, me.Name, me.UserID)
+						return nil
+					},
+				},
+				{
+					Name:  "logout",
+					Usage: "Remove the credential of the space of current repository",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "url",
+							Usage: "Base URL of the space instead of the one of current repository",
+						},
+						cli.BoolFlag{
+							Name:  "all",
+							Usage: "Remove the credentials of all spaces",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						if c.Bool("all") {
+							return exit(Logout(credentialsFile(), ""))
+						}
+						baseURL, _, _, err := spaceURL(c)
+						if err != nil {
+							return exit(err)
+						}
+						if err := Logout(credentialsFile(), baseURL); err != nil {
+							return exit(err)
+						}
+						fmt.Printf("Logged out of %s\n", baseURL)
+						return nil
+					},
+				},
+				{
+					Name:  "status",
+					Usage: "Show the spaces logged in and as whom",
+					Action: func(c *cli.Context) error {
+						return exit(AuthStatus(context.Background(), os.Stdout, credentialsFile(), backlog.NewClient))
+					},
+				},
+			},
+		},
 	}
 	app.OnUsageError = func(context *cli.Context, err error, isSubcommand bool) error {
 		if isSubcommand {
@@ -279,8 +1070,7 @@ func main() {
 		return nil
 	}
 	app.CommandNotFound = func(c *cli.Context, name string) {
-		args := append([]string{name}, os.Args[2:]...)
-		if err := NewGitCmd(args).Run(); err != nil {
+		if err := NewGitCmd(c.Args()).Run(); err != nil {
 			log.Fatalln(err)
 		}
 	}
@@ -294,10 +1084,146 @@ func exit(err error) error {
 	return nil
 }
 
-func open(path string) (*BacklogRepository, error) {
-	repo, err := OpenRepository(path)
+// stringOrConfig returns the value of the flag, or the value of key in cfg
+// when the flag is not given.
+func stringOrConfig(c *cli.Context, flag string, cfg *Config, key string) string {
+	if v := c.String(flag); v != "" {
+		return v
+	}
+	return cfg.Get(key)
+}
+
+// commentMessage returns the comment given by -m, or read from the file of
+// -F. It is empty when neither is given, so that the editor is opened.
+func commentMessage(c *cli.Context) (string, error) {
+	file := c.String("message-file")
+	if file == "" {
+		return c.String("message"), nil
+	}
+	if c.String("message") != "" {
+		return "", errors.New("specify either -m or -F")
+	}
+	body, err := readBodyFile(file)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(body) == "" {
+		return "", errors.New("comment is empty")
+	}
+	return body, nil
+}
+
+// prDiffFlags takes the flags of pr diff and pr files out of args. The other
+// arguments are passed to git diff, and so is everything after "--".
+func prDiffFlags(args []string) (base string, online, help bool, rest []string, err error) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			return base, online, help, append(rest, args[i:]...), nil
+		case arg == "-b" || arg == "--base":
+			if i+1 == len(args) {
+				return "", false, false, nil, errors.Errorf("%s requires a branch", arg)
+			}
+			i++
+			base = args[i]
+		case strings.HasPrefix(arg, "--base="):
+			base = strings.TrimPrefix(arg, "--base=")
+		case arg == "--online":
+			online = true
+		case arg == "-h" || arg == "--help":
+			help = true
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return base, online, help, rest, nil
+}
+
+// prIDAndArgs splits the arguments into the leading PR-ID, if any, and the rest.
+func prIDAndArgs(args []string) (string, []string) {
+	if len(args) > 0 {
+		if _, err := strconv.Atoi(strings.TrimPrefix(args[0], "#")); err == nil {
+			return args[0], args[1:]
+		}
+	}
+	return "", args
+}
+
+// blameFormatAndArgs takes the options of gitb from the arguments of pr
+// blame and issue blame, which are passed to git blame otherwise.
+func blameFormatAndArgs(args []string) (*BlameFormat, []string) {
+	f := &BlameFormat{}
+	var rest []string
+	for i, arg := range args {
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		switch arg {
+		case "--json":
+			f.JSON = true
+		case "--porcelain":
+			f.Porcelain = true
+		case "--summary":
+			f.Summary = true
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return f, rest
+}
+
+func open(c *cli.Context) (*BacklogRepository, error) {
+	cfg, err := LoadConfig(".")
+	if err != nil {
+		return nil, err
+	}
+	remote := c.GlobalString("remote")
+	if remote == "" {
+		remote = cfg.Get("remote")
+	}
+	repo, err := OpenRepository(".", remote, cfg.Hosts())
 	if err != nil {
 		return nil, err
 	}
-	return NewBacklogRepository(repo), nil
+	b, err := NewBacklogRepository(repo, cfg)
+	if IsNotBacklogRemote(err) {
+		return nil, errors.Errorf("remote %q does not point to Backlog (%v)\n"+
+			"choose the Backlog remote with --remote <name> or git config %s, "+
+			"or configure the host for Backlog Enterprise", repo.RemoteName(), err, remoteConfigKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	b.openURL = urlSink(c, b.openURL)
+	return b, nil
+}
+
+// urlSink returns the sink chosen by --copy or --print, or fallback.
+func urlSink(c *cli.Context, fallback URLSink) URLSink {
+	switch {
+	case c.GlobalBool("copy"):
+		return CopySink(openTTY())
+	case c.GlobalBool("print"):
+		return PrintSink(os.Stdout)
+	}
+	return fallback
+}
+
+// spaceURL returns the base URL given by --url, or the one of the current
+// repository, with the config and the sink for URLs.
+func spaceURL(c *cli.Context) (string, *Config, URLSink, error) {
+	if u := c.String("url"); u != "" {
+		cfg, err := LoadConfig(".")
+		if err != nil {
+			return "", nil, nil, err
+		}
+		return strings.TrimSuffix(u, "/"), cfg, urlSink(c, defaultURLSink(cfg)), nil
+	}
+	repo, err := open(c)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return repo.urlBuilder().BaseURL(), repo.config, repo.openURL, nil
 }
@@ -0,0 +1,649 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/vvatanabe/gitb/internal/backlog"
+)
+
+// CreatePullRequestOptions is given by the flags of `gitb pr create`.
+// Empty fields are filled from the repository.
+type CreatePullRequestOptions struct {
+	Base     string
+	Title    string
+	Body     string
+	Issue    string
+	Assignee string
+	Notify   []string
+	// Push pushes the current branch when it is not pushed yet.
+	Push bool
+}
+
+// CreatePullRequest creates a pull request of the current branch through
+// Backlog API, and returns its URL.
+func (b *BacklogRepository) CreatePullRequest(opt *CreatePullRequestOptions) (string, error) {
+	ctx := context.Background()
+	branch := b.repo.HeadShortName()
+	if !strings.HasPrefix(b.repo.HeadName(), refBranchPrefix) {
+		return "", errors.New("not on a branch")
+	}
+	base := opt.Base
+	if base == "" {
+		var err error
+		base, err = b.repo.DefaultBranch()
+		if err != nil {
+			return "", err
+		}
+	}
+	if base == branch {
+		return "", errors.Errorf("the branch %s is the base branch", branch)
+	}
+	// The inputs are resolved before pushing, so that an error does not
+	// leave the branch pushed without a pull request.
+	client, err := b.APIClient()
+	if err != nil {
+		return "", err
+	}
+	title, body := opt.Title, opt.Body
+	if title == "" || body == "" {
+		commits, err := b.repo.CommitsSince(base)
+		if err != nil {
+			return "", err
+		}
+		if len(commits) == 0 {
+			return "", errors.Errorf("no commits between %s and %s", base, branch)
+		}
+		t, d := describeCommits(commits)
+		if title == "" {
+			title = t
+		}
+		if body == "" {
+			body = d
+		}
+	}
+	add := &backlog.AddPullRequestOptions{
+		Summary:     title,
+		Description: body,
+		Base:        base,
+		Branch:      branch,
+	}
+	issueID, err := b.findIssueID(ctx, client, opt.Issue, branch)
+	if err != nil {
+		return "", err
+	}
+	add.IssueID = issueID
+	if opt.Assignee != "" || len(opt.Notify) > 0 {
+		users, err := client.GetProjectUsers(ctx, b.projectKey)
+		if err != nil {
+			return "", err
+		}
+		if opt.Assignee != "" {
+			u, err := findUser(users, opt.Assignee)
+			if err != nil {
+				return "", err
+			}
+			add.AssigneeID = u.ID
+		}
+		for _, v := range opt.Notify {
+			u, err := findUser(users, v)
+			if err != nil {
+				return "", err
+			}
+			add.NotifiedUserIDs = append(add.NotifiedUserIDs, u.ID)
+		}
+	}
+	if err := b.ensurePushed(branch, opt.Push); err != nil {
+		return "", err
+	}
+	pr, err := client.AddPullRequest(ctx, b.projectKey, b.repoName, add)
+	if err != nil {
+		return "", err
+	}
+	return b.urlBuilder().PullRequestURL(strconv.Itoa(pr.Number)), nil
+}
+
+// ensurePushed checks that the remote has the branch at HEAD. When push is
+// true, the branch is pushed instead of refusing.
+func (b *BacklogRepository) ensurePushed(branch string, push bool) error {
+	refToHash, err := b.repo.LsRemote()
+	if err != nil {
+		return err
+	}
+	if refToHash[refBranchPrefix+branch] == b.repo.HeadHash() {
+		return nil
+	}
+	if !push {
+		return errors.Errorf("the branch %s is not pushed to %s. push it, or use --push", branch, b.repo.RemoteName())
+	}
+	return b.repo.Push(branch)
+}
+
+// findIssueID returns the ID of the issue to link. When key is empty, the
+// issue key in the branch name is used if the issue exists.
+func (b *BacklogRepository) findIssueID(ctx context.Context, client *backlog.Client, key, branch string) (int, error) {
+	explicit := key != ""
+	if !explicit {
+		key = extractIssueKey(branch)
+	}
+	if key == "" {
+		return 0, nil
+	}
+	issue, err := client.GetIssue(ctx, key)
+	if !explicit && backlog.IsNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not get the issue %s", key)
+	}
+	return issue.ID, nil
+}
+
+// describeCommits returns the title and the description of a pull request
+// made of commits: the subject of the first commit, and the log of them.
+func describeCommits(commits []*Commit) (title, body string) {
+	title = commits[0].Subject
+	if len(commits) == 1 {
+		return title, commits[0].Body
+	}
+	var lines []string
+	for _, c := range commits {
+		lines = append(lines, "* "+c.Subject)
+	}
+	return title, strings.Join(lines, "\n")
+}
+
+// findUser finds the user by the user ID used to log in, or by the name.
+func findUser(users []*backlog.User, s string) (*backlog.User, error) {
+	for _, u := range users {
+		if u.UserID == s {
+			return u, nil
+		}
+	}
+	for _, u := range users {
+		if u.Name == s {
+			return u, nil
+		}
+	}
+	return nil, errors.Errorf("could not find the user %s in the project", s)
+}
+
+// ListPullRequestsOptions filters pull requests of `gitb pr list`.
+type ListPullRequestsOptions struct {
+	// State is one of the values of PRStatusFromString.
+	State    string
+	Author   string
+	Assignee string
+	Issue    string
+	Base     string
+	// Limit is the maximum number of pull requests. Zero means all.
+	Limit int
+}
+
+// ListPullRequests fetches pull requests of the repository, newest first.
+func (b *BacklogRepository) ListPullRequests(opt *ListPullRequestsOptions) ([]*backlog.PullRequest, error) {
+	ctx := context.Background()
+	s, err := PRStatusFromString(opt.State)
+	if err != nil {
+		return nil, err
+	}
+	client, err := b.APIClient()
+	if err != nil {
+		return nil, err
+	}
+	list := &backlog.PullRequestListOptions{Limit: opt.Limit}
+	if s != PRStatusAll {
+		list.StatusIDs = []int{s.Int()}
+	}
+	if opt.Author != "" || opt.Assignee != "" {
+		users, err := client.GetProjectUsers(ctx, b.projectKey)
+		if err != nil {
+			return nil, err
+		}
+		if opt.Author != "" {
+			u, err := findUser(users, opt.Author)
+			if err != nil {
+				return nil, err
+			}
+			list.CreatedUserIDs = []int{u.ID}
+		}
+		if opt.Assignee != "" {
+			u, err := findUser(users, opt.Assignee)
+			if err != nil {
+				return nil, err
+			}
+			list.AssigneeIDs = []int{u.ID}
+		}
+	}
+	if opt.Issue != "" {
+		issue, err := client.GetIssue(ctx, opt.Issue)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not get the issue %s", opt.Issue)
+		}
+		list.IssueIDs = []int{issue.ID}
+	}
+	if opt.Base == "" {
+		return client.GetPullRequests(ctx, b.projectKey, b.repoName, list)
+	}
+	// The API cannot filter by the base branch, so pages are fetched until
+	// enough pull requests of the base are found.
+	list.Limit = 0
+	var filtered []*backlog.PullRequest
+	err = b.eachPullRequest(ctx, client, list, func(pr *backlog.PullRequest) bool {
+		if pr.Base == opt.Base {
+			filtered = append(filtered, pr)
+		}
+		return opt.Limit <= 0 || len(filtered) < opt.Limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	return filtered, nil
+}
+
+// pullRequestPageSize is the number of pull requests eachPullRequest fetches
+// at once.
+var pullRequestPageSize = 100
+
+// eachPullRequest calls f with the pull requests of list, newest first,
+// fetching a page at a time until f returns false. list.Limit is the maximum
+// number of pull requests to look at. Zero means all.
+func (b *BacklogRepository) eachPullRequest(ctx context.Context, client *backlog.Client, list *backlog.PullRequestListOptions, f func(*backlog.PullRequest) bool) error {
+	opt := *list
+	for seen := 0; list.Limit <= 0 || seen < list.Limit; {
+		opt.Offset = list.Offset + seen
+		opt.Limit = pullRequestPageSize
+		if list.Limit > 0 && list.Limit-seen < opt.Limit {
+			opt.Limit = list.Limit - seen
+		}
+		page, err := client.GetPullRequests(ctx, b.projectKey, b.repoName, &opt)
+		if err != nil {
+			return err
+		}
+		for _, pr := range page {
+			if !f(pr) {
+				return nil
+			}
+		}
+		seen += len(page)
+		if len(page) < opt.Limit {
+			return nil
+		}
+	}
+	return nil
+}
+
+// PrintPullRequests prints pull requests as an aligned table, or as f says.
+func PrintPullRequests(w io.Writer, prs []*backlog.PullRequest, f *ListFormat) error {
+	if ok, err := f.printList(w, prs); ok {
+		return err
+	}
+	var rows [][]string
+	for _, pr := range prs {
+		status := ""
+		if pr.Status != nil {
+			status = colorize(pr.Status.Name, prStatusColor(pr.Status.ID), f.Color)
+		}
+		assignee := "-"
+		if pr.Assignee != nil {
+			assignee = pr.Assignee.Name
+		}
+		rows = append(rows, []string{
+			colorize("#"+strconv.Itoa(pr.Number), colorCyan, f.Color),
+			status,
+			pr.Summary,
+			pr.Branch + " -> " + pr.Base,
+			assignee,
+			pr.Updated.Local().Format("2006-01-02"),
+		})
+	}
+	return printTable(w, rows)
+}
+
+func prStatusColor(id int) int {
+	switch id {
+	case backlog.PullRequestStatusOpen:
+		return colorGreen
+	case backlog.PullRequestStatusClosed:
+		return colorRed
+	case backlog.PullRequestStatusMerged:
+		return colorMagenta
+	}
+	return colorGray
+}
+
+// CheckoutPullRequestOptions is given by the flags of `gitb pr checkout`.
+type CheckoutPullRequestOptions struct {
+	// Worktree checks out into a new worktree instead of the current one.
+	Worktree bool
+	// Path is the path of the worktree. Default is <repo>-pr-<id> beside the repository.
+	Path string
+}
+
+// CheckoutPullRequest fetches refs/pull/<id>/head into a local branch that
+// tracks it, and checks it out. The branch is named after the source branch
+// of the pull request when Backlog API is available, or pr/<id> otherwise.
+func (b *BacklogRepository) CheckoutPullRequest(id string, opt *CheckoutPullRequestOptions) error {
+	number, err := strconv.Atoi(strings.TrimPrefix(id, "#"))
+	if err != nil || number <= 0 {
+		return errors.Errorf("invalid pull request id: %s", id)
+	}
+	p := &prCheckout{
+		remote: b.repo.RemoteName(),
+		number: strconv.Itoa(number),
+		ref:    refPullRequestPrefix + strconv.Itoa(number) + refPullRequestSuffix,
+		branch: "pr/" + strconv.Itoa(number),
+	}
+	if client, err := b.APIClient(); err == nil {
+		pr, err := client.GetPullRequest(context.Background(), b.projectKey, b.repoName, number)
+		if err != nil {
+			return err
+		}
+		p.branch = pr.Branch
+	}
+	if err := b.repo.Git("rev-parse", "--verify", "--quiet", refBranchPrefix+p.branch).Run(); err == nil {
+		p.exists = true
+		out, _ := b.repo.Git("config", "branch."+p.branch+"."+prBranchConfigKey).Output()
+		p.tracking = strings.TrimSpace(string(out))
+	}
+	p.current = b.repo.HeadName() == refBranchPrefix+p.branch
+	if opt.Worktree {
+		if opt.Path != "" {
+			// git runs in the root directory, so the path given is resolved here.
+			if p.worktree, err = filepath.Abs(opt.Path); err != nil {
+				return err
+			}
+		} else {
+			root := b.repo.RootDirectory()
+			p.worktree = filepath.Join(filepath.Dir(root), filepath.Base(root)+"-pr-"+strconv.Itoa(number))
+		}
+	}
+	commands, err := p.commands()
+	if err != nil {
+		return err
+	}
+	for _, argv := range commands {
+		cmd := b.repo.Git(argv...)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return errors.Wrapf(err, "git %s", strings.Join(argv, " "))
+		}
+	}
+	return nil
+}
+
+// prBranchConfigKey records the pull request which a local branch tracks.
+// branch.<name>.merge cannot be used, as go-git rejects refs other than
+// branches there.
+const prBranchConfigKey = "gitbPullRequest"
+
+// prCheckout is the state of the repository to check out a pull request.
+type prCheckout struct {
+	remote string
+	number string
+	ref    string
+	branch string
+	// exists reports whether the local branch exists, and tracking is the
+	// number of the pull request it tracks.
+	exists   bool
+	tracking string
+	current  bool
+	worktree string
+}
+
+// commands returns the arguments of git to check out the pull request. An
+// existing branch is only fast-forwarded, so that local commits are not lost.
+func (p *prCheckout) commands() ([][]string, error) {
+	if !p.exists {
+		var checkout []string
+		if p.worktree != "" {
+			checkout = []string{"worktree", "add", "-b", p.branch, p.worktree, "FETCH_HEAD"}
+		} else {
+			checkout = []string{"checkout", "-b", p.branch, "FETCH_HEAD"}
+		}
+		return [][]string{
+			{"fetch", p.remote, p.ref},
+			checkout,
+			{"config", "branch." + p.branch + "." + prBranchConfigKey, p.number},
+		}, nil
+	}
+	if p.tracking != p.number {
+		return nil, errors.Errorf("the branch %s exists and does not track pull request #%s. check it out with git checkout", p.branch, p.number)
+	}
+	if p.current {
+		if p.worktree != "" {
+			return nil, errors.Errorf("the branch %s is checked out in the current worktree", p.branch)
+		}
+		return [][]string{
+			{"fetch", p.remote, p.ref},
+			{"merge", "--ff-only", "FETCH_HEAD"},
+		}, nil
+	}
+	checkout := []string{"checkout", p.branch}
+	if p.worktree != "" {
+		checkout = []string{"worktree", "add", p.worktree, p.branch}
+	}
+	return [][]string{
+		{"fetch", p.remote, p.ref + ":" + refBranchPrefix + p.branch},
+		checkout,
+	}, nil
+}
+
+// DiffPullRequest shows the diff of the pull request since the merge-base
+// with its base branch, through git diff so that the pager and the diff
+// config of the user apply. stat shows the changed files instead. When id
+// is empty, the pull request of the current branch is shown.
+func (b *BacklogRepository) DiffPullRequest(id, base string, stat bool, args []string) error {
+	baseHash, headHash, err := b.pullRequestRange(id, base)
+	if err != nil {
+		return err
+	}
+	argv := prDiffArgs(baseHash, headHash, stat, args)
+	cmd := b.repo.Git(argv...)
+	// The paths given are relative to the working directory, like git diff.
+	cmd.Dir = ""
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// pullRequestRange fetches the head of the pull request and its base branch,
+// and returns their hashes. The base branch is given, or of the pull request
+// when Backlog API is available, or the default branch of the remote.
+func (b *BacklogRepository) pullRequestRange(id, base string) (baseHash, headHash string, err error) {
+	if id == "" {
+		id, err = b.findPullRequestID(b.repo.HeadName())
+		if err != nil {
+			return "", "", err
+		}
+	}
+	number, err := strconv.Atoi(strings.TrimPrefix(id, "#"))
+	if err != nil || number <= 0 {
+		return "", "", errors.Errorf("invalid pull request id: %s", id)
+	}
+	if base == "" {
+		if client, err := b.APIClient(); err == nil {
+			pr, err := client.GetPullRequest(context.Background(), b.projectKey, b.repoName, number)
+			if err != nil {
+				return "", "", err
+			}
+			base = pr.Base
+		} else if base, err = b.repo.DefaultBranch(); err != nil {
+			return "", "", err
+		}
+	}
+	headRef := refPullRequestPrefix + strconv.Itoa(number) + refPullRequestSuffix
+	refToHash, err := b.remoteRefs(headRef, refBranchPrefix+base)
+	if err != nil {
+		return "", "", err
+	}
+	headHash, ok := refToHash[headRef]
+	if !ok {
+		return "", "", errors.Errorf("not found pull request #%d in %s", number, b.repo.RemoteName())
+	}
+	baseHash, ok = refToHash[refBranchPrefix+base]
+	if !ok {
+		return "", "", errors.Errorf("not found the base branch %s in %s", base, b.repo.RemoteName())
+	}
+	if err := b.repo.Fetch(headRef, refBranchPrefix+base); err != nil {
+		return "", "", err
+	}
+	return baseHash, headHash, nil
+}
+
+// prDiffArgs returns the arguments of git diff from the merge-base of base
+// to head. The range is put before "--" in args, which is followed by paths.
+func prDiffArgs(base, head string, stat bool, args []string) []string {
+	argv := []string{"diff"}
+	if stat {
+		argv = append(argv, "--stat")
+	}
+	var paths []string
+	for i, v := range args {
+		if v == "--" {
+			args, paths = args[:i], args[i:]
+			break
+		}
+	}
+	argv = append(argv, args...)
+	argv = append(argv, base+"..."+head)
+	return append(argv, paths...)
+}
+
+// FetchPullRequests configures the remote to fetch the heads of pull requests
+// into refs/remotes/<remote>/pr/<id>, and fetches them. Then pull requests
+// are looked up in them without asking the remote.
+func (b *BacklogRepository) FetchPullRequests() error {
+	remote := b.repo.RemoteName()
+	spec := prFetchRefspec(remote)
+	out, _ := b.repo.Git("config", "--get-all", "remote."+remote+".fetch").Output()
+	if !containsLine(string(out), spec) {
+		if err := b.repo.Git("config", "--add", "remote."+remote+".fetch", spec).Run(); err != nil {
+			return errors.Wrap(err, "could not configure the refspec")
+		}
+		fmt.Fprintf(os.Stderr, "Added %s to remote.%s.fetch\n", spec, remote)
+	}
+	cmd := b.repo.Git("fetch", remote)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func prFetchRefspec(remote string) string {
+	return "+" + refPullRequestPrefix + "*" + refPullRequestSuffix + ":refs/remotes/" + remote + "/" + prRemoteTrackingPrefix + "*"
+}
+
+func containsLine(s, line string) bool {
+	for _, v := range strings.Split(s, "\n") {
+		if strings.TrimSpace(v) == line {
+			return true
+		}
+	}
+	return false
+}
+
+// CommitLanding is a branch which a commit landed on, with the pull request
+// which brought it there.
+type CommitLanding struct {
+	Branch      string
+	PullRequest int
+}
+
+// PullRequestForCommit returns the pull request which brought commit into the
+// default branch of the remote.
+func (b *BacklogRepository) PullRequestForCommit(commit string) (int, error) {
+	hash, err := b.verifyCommit(commit)
+	if err != nil {
+		return 0, err
+	}
+	base, err := b.repo.DefaultBranch()
+	if err != nil {
+		return 0, err
+	}
+	resolver, err := b.newPRResolver()
+	if err != nil {
+		return 0, err
+	}
+	ref := "refs/remotes/" + b.repo.RemoteName() + "/" + base
+	if err := b.repo.Git("merge-base", "--is-ancestor", hash, ref).Run(); err != nil {
+		return 0, errors.Errorf("%s has not landed on %s/%s. fetch it, or try --all-branches", commit, b.repo.RemoteName(), base)
+	}
+	landings, err := resolver.landings(hash, []string{ref})
+	if err != nil {
+		return 0, err
+	}
+	if landings[0].PullRequest == 0 {
+		return 0, errors.Errorf("could not find the pull request which brought %s into %s", commit, base)
+	}
+	return landings[0].PullRequest, nil
+}
+
+// OpenPullRequestForCommit opens the pull request which brought commit into
+// the default branch.
+func (b *BacklogRepository) OpenPullRequestForCommit(commit string) error {
+	id, err := b.PullRequestForCommit(commit)
+	if err != nil {
+		return err
+	}
+	return b.OpenPullRequestByID(strconv.Itoa(id))
+}
+
+// PrintCommitLandings prints every remote branch which commit landed on, with
+// the pull request which brought it there.
+func (b *BacklogRepository) PrintCommitLandings(w io.Writer, commit string) error {
+	hash, err := b.verifyCommit(commit)
+	if err != nil {
+		return err
+	}
+	resolver, err := b.newPRResolver()
+	if err != nil {
+		return err
+	}
+	prefix := "refs/remotes/" + b.repo.RemoteName() + "/"
+	out, err := b.runGit("for-each-ref", "--contains", hash, "--format=%(refname)", prefix)
+	if err != nil {
+		return errors.Wrap(err, "could not list the remote branches")
+	}
+	var refs []string
+	for _, ref := range strings.Fields(out) {
+		name := strings.TrimPrefix(ref, prefix)
+		if name == "HEAD" || strings.HasPrefix(name, prRemoteTrackingPrefix) {
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	if len(refs) == 0 {
+		return errors.Errorf("%s has not landed on any branches of %s", commit, b.repo.RemoteName())
+	}
+	landings, err := resolver.landings(hash, refs)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, l := range landings {
+		name := strings.TrimPrefix(l.Branch, prefix)
+		if l.PullRequest == 0 {
+			fmt.Fprintf(tw, "%s\t-\t\n", name)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\tPR #%d\t%s\n", name, l.PullRequest, b.urlBuilder().PullRequestURL(strconv.Itoa(l.PullRequest)))
+	}
+	return tw.Flush()
+}
+
+func (b *BacklogRepository) verifyCommit(commit string) (string, error) {
+	out, err := b.runGit("rev-parse", "--verify", "--quiet", commit+"^{commit}")
+	if err != nil {
+		return "", errors.Errorf("unknown commit %s. fetch it first", commit)
+	}
+	return strings.TrimSpace(out), nil
+}
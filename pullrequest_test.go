@@ -0,0 +1,916 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vvatanabe/gitb/internal/backlog"
+)
+
+// newAPIServer serves the responses by "METHOD path", and records the forms
+// of requests, or the queries without apiKey for GET.
+func newAPIServer(t *testing.T, responses map[string]interface{}, forms map[string]url.Values) *backlog.Client {
+	t.Helper()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Method + " " + r.URL.Path
+		if forms != nil {
+			if r.Method == http.MethodGet {
+				q := r.URL.Query()
+				q.Del("apiKey")
+				forms[key] = q
+			} else {
+				_ = r.ParseForm()
+				forms[key] = r.PostForm
+			}
+		}
+		v, ok := responses[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[{"message":"No such resource."}]}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(v)
+	}))
+	t.Cleanup(s.Close)
+	return backlog.NewClient(s.URL).SetAPIKey("key")
+}
+
+func newPRRepository(pushed bool, pushedBranch *string) *RepositoryMock {
+	remoteHash := "e73e35d0a86218a9624167110ff8e7fe42596234"
+	if pushed {
+		remoteHash = "2b2b5f9e8508a976096a50bd37c81c17ccdf7fb4"
+	}
+	return &RepositoryMock{
+		HeadNameFunc: func() string {
+			return "refs/heads/feature/BAR-1"
+		},
+		HeadShortNameFunc: func() string {
+			return "feature/BAR-1"
+		},
+		HeadHashFunc: func() string {
+			return "2b2b5f9e8508a976096a50bd37c81c17ccdf7fb4"
+		},
+		RemoteNameFunc: func() string {
+			return "origin"
+		},
+		LsRemoteFunc: func() (RefToHash, error) {
+			return RefToHash{
+				"refs/heads/master":        "e73e35d0a86218a9624167110ff8e7fe42596234",
+				"refs/heads/feature/BAR-1": remoteHash,
+			}, nil
+		},
+		DefaultBranchFunc: func() (string, error) {
+			return "master", nil
+		},
+		CommitsSinceFunc: func(base string) ([]*Commit, error) {
+			return []*Commit{
+				{Hash: "1", Subject: "Add foo"},
+				{Hash: "2", Subject: "Fix foo"},
+			}, nil
+		},
+		PushFunc: func(branch string) error {
+			*pushedBranch = branch
+			return nil
+		},
+	}
+}
+
+func TestBacklogRepository_CreatePullRequest(t *testing.T) {
+	setenv(t, apiKeyEnv, "")
+	setenv(t, "XDG_CONFIG_HOME", t.TempDir())
+	responses := map[string]interface{}{
+		"GET /api/v2/issues/BAR-1": &backlog.Issue{ID: 10, IssueKey: "BAR-1"},
+		"GET /api/v2/projects/BAR/users": []*backlog.User{
+			{ID: 1, UserID: "alice", Name: "Alice"},
+			{ID: 2, UserID: "bob", Name: "Bob"},
+		},
+		"POST /api/v2/projects/BAR/git/repositories/baz/pullRequests": &backlog.PullRequest{Number: 7},
+	}
+	tests := []struct {
+		name       string
+		pushed     bool
+		noAPI      bool
+		opt        *CreatePullRequestOptions
+		want       string
+		wantForm   url.Values
+		wantPushed string
+		wantErr    bool
+	}{
+		{
+			name:   "defaults",
+			pushed: true,
+			opt:    &CreatePullRequestOptions{},
+			want:   "https://foo.backlog.com/git/BAR/baz/pullRequests/7",
+			wantForm: url.Values{
+				"summary":     {"Add foo"},
+				"description": {"* Add foo\n* Fix foo"},
+				"base":        {"master"},
+				"branch":      {"feature/BAR-1"},
+				"issueId":     {"10"},
+			},
+		},
+		{
+			name:   "flags",
+			pushed: true,
+			opt: &CreatePullRequestOptions{
+				Base:     "develop",
+				Title:    "Title",
+				Body:     "Body",
+				Assignee: "alice",
+				Notify:   []string{"Bob"},
+			},
+			want: "https://foo.backlog.com/git/BAR/baz/pullRequests/7",
+			wantForm: url.Values{
+				"summary":          {"Title"},
+				"description":      {"Body"},
+				"base":             {"develop"},
+				"branch":           {"feature/BAR-1"},
+				"issueId":          {"10"},
+				"assigneeId":       {"1"},
+				"notifiedUserId[]": {"2"},
+			},
+		},
+		{
+			name:    "not pushed",
+			opt:     &CreatePullRequestOptions{},
+			wantErr: true,
+		},
+		{
+			name:       "push",
+			opt:        &CreatePullRequestOptions{Push: true, Title: "Title", Body: "Body"},
+			want:       "https://foo.backlog.com/git/BAR/baz/pullRequests/7",
+			wantPushed: "feature/BAR-1",
+			wantForm: url.Values{
+				"summary":     {"Title"},
+				"description": {"Body"},
+				"base":        {"master"},
+				"branch":      {"feature/BAR-1"},
+				"issueId":     {"10"},
+			},
+		},
+		{
+			name:    "push with unknown assignee",
+			opt:     &CreatePullRequestOptions{Push: true, Title: "Title", Body: "Body", Assignee: "carol"},
+			wantErr: true,
+		},
+		{
+			name:    "push without login",
+			noAPI:   true,
+			opt:     &CreatePullRequestOptions{Push: true, Title: "Title", Body: "Body"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown issue",
+			pushed:  true,
+			opt:     &CreatePullRequestOptions{Issue: "BAR-2"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown assignee",
+			pushed:  true,
+			opt:     &CreatePullRequestOptions{Assignee: "carol"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forms := make(map[string]url.Values)
+			var pushed string
+			api := newAPIServer(t, responses, forms)
+			if tt.noAPI {
+				api = nil
+			}
+			b := &BacklogRepository{
+				repo:       newPRRepository(tt.pushed, &pushed),
+				api:        api,
+				domain:     "backlog.com",
+				spaceKey:   "foo",
+				projectKey: "BAR",
+				repoName:   "baz",
+			}
+			got, err := b.CreatePullRequest(tt.opt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BacklogRepository.CreatePullRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("BacklogRepository.CreatePullRequest() = %v, want %v", got, tt.want)
+			}
+			if pushed != tt.wantPushed {
+				t.Errorf("BacklogRepository.CreatePullRequest() pushed %q, want %q", pushed, tt.wantPushed)
+			}
+			if tt.wantErr {
+				return
+			}
+			gotForm := forms["POST /api/v2/projects/BAR/git/repositories/baz/pullRequests"]
+			if !reflect.DeepEqual(gotForm, tt.wantForm) {
+				t.Errorf("BacklogRepository.CreatePullRequest() form = %v, want %v", gotForm, tt.wantForm)
+			}
+		})
+	}
+}
+
+func Test_describeCommits(t *testing.T) {
+	tests := []struct {
+		name      string
+		commits   []*Commit
+		wantTitle string
+		wantBody  string
+	}{
+		{
+			name:      "single",
+			commits:   []*Commit{{Subject: "Add foo", Body: "Because of bar."}},
+			wantTitle: "Add foo",
+			wantBody:  "Because of bar.",
+		},
+		{
+			name: "multiple",
+			commits: []*Commit{
+				{Subject: "Add foo", Body: "Because of bar."},
+				{Subject: "Fix foo"},
+			},
+			wantTitle: "Add foo",
+			wantBody:  "* Add foo\n* Fix foo",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotTitle, gotBody := describeCommits(tt.commits)
+			if gotTitle != tt.wantTitle {
+				t.Errorf("describeCommits() gotTitle = %v, want %v", gotTitle, tt.wantTitle)
+			}
+			if gotBody != tt.wantBody {
+				t.Errorf("describeCommits() gotBody = %v, want %v", gotBody, tt.wantBody)
+			}
+		})
+	}
+}
+
+func Test_findUser(t *testing.T) {
+	users := []*backlog.User{
+		{ID: 1, UserID: "alice", Name: "bob"},
+		{ID: 2, UserID: "bob", Name: "Bob"},
+	}
+	tests := []struct {
+		s       string
+		wantID  int
+		wantErr bool
+	}{
+		{s: "alice", wantID: 1},
+		{s: "bob", wantID: 2},
+		{s: "Bob", wantID: 2},
+		{s: "carol", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := findUser(users, tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("findUser() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.ID != tt.wantID {
+				t.Errorf("findUser() = %v, want %v", got.ID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestBacklogRepository_ListPullRequests(t *testing.T) {
+	responses := map[string]interface{}{
+		"GET /api/v2/issues/BAR-1": &backlog.Issue{ID: 10, IssueKey: "BAR-1"},
+		"GET /api/v2/projects/BAR/users": []*backlog.User{
+			{ID: 1, UserID: "alice", Name: "Alice"},
+			{ID: 2, UserID: "bob", Name: "Bob"},
+		},
+		"GET /api/v2/projects/BAR/git/repositories/baz/pullRequests": []*backlog.PullRequest{
+			{Number: 3, Base: "master"},
+			{Number: 2, Base: "develop"},
+			{Number: 1, Base: "master"},
+		},
+	}
+	tests := []struct {
+		name        string
+		opt         *ListPullRequestsOptions
+		wantNumbers []int
+		wantQuery   url.Values
+		wantErr     bool
+	}{
+		{
+			name:        "open",
+			opt:         &ListPullRequestsOptions{State: "open", Limit: 30},
+			wantNumbers: []int{3, 2, 1},
+			wantQuery: url.Values{
+				"statusId[]": {"1"},
+				"offset":     {"0"},
+				"count":      {"30"},
+			},
+		},
+		{
+			name:        "filters",
+			opt:         &ListPullRequestsOptions{State: "all", Author: "alice", Assignee: "Bob", Issue: "BAR-1", Limit: 30},
+			wantNumbers: []int{3, 2, 1},
+			wantQuery: url.Values{
+				"createdUserId[]": {"1"},
+				"assigneeId[]":    {"2"},
+				"issueId[]":       {"10"},
+				"offset":          {"0"},
+				"count":           {"30"},
+			},
+		},
+		{
+			name:        "base",
+			opt:         &ListPullRequestsOptions{State: "merged", Base: "master", Limit: 1},
+			wantNumbers: []int{3},
+			wantQuery: url.Values{
+				"statusId[]": {"3"},
+				"offset":     {"0"},
+				"count":      {"100"},
+			},
+		},
+		{
+			name:    "invalid state",
+			opt:     &ListPullRequestsOptions{State: "draft"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forms := make(map[string]url.Values)
+			b := &BacklogRepository{
+				api:        newAPIServer(t, responses, forms),
+				domain:     "backlog.com",
+				spaceKey:   "foo",
+				projectKey: "BAR",
+				repoName:   "baz",
+			}
+			got, err := b.ListPullRequests(tt.opt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BacklogRepository.ListPullRequests() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var gotNumbers []int
+			for _, pr := range got {
+				gotNumbers = append(gotNumbers, pr.Number)
+			}
+			if !reflect.DeepEqual(gotNumbers, tt.wantNumbers) {
+				t.Errorf("BacklogRepository.ListPullRequests() = %v, want %v", gotNumbers, tt.wantNumbers)
+			}
+			if tt.wantErr {
+				return
+			}
+			gotQuery := forms["GET /api/v2/projects/BAR/git/repositories/baz/pullRequests"]
+			if !reflect.DeepEqual(gotQuery, tt.wantQuery) {
+				t.Errorf("BacklogRepository.ListPullRequests() query = %v, want %v", gotQuery, tt.wantQuery)
+			}
+		})
+	}
+}
+
+func TestPrintPullRequests(t *testing.T) {
+	updated := time.Date(2020, 1, 2, 12, 0, 0, 0, time.Local)
+	prs := []*backlog.PullRequest{
+		{
+			Number:   12,
+			Summary:  "Add foo",
+			Base:     "master",
+			Branch:   "feature/foo",
+			Status:   &backlog.Status{ID: 1, Name: "Open"},
+			Assignee: &backlog.User{Name: "Alice"},
+			Updated:  updated,
+		},
+		{
+			Number:  3,
+			Summary: "Fix bar",
+			Base:    "develop",
+			Branch:  "fix/bar",
+			Status:  &backlog.Status{ID: 3, Name: "Merged"},
+			Updated: updated,
+		},
+	}
+	tests := []struct {
+		name string
+		f    *ListFormat
+		want string
+	}{
+		{
+			name: "table",
+			f:    &ListFormat{},
+			want: "#12  Open    Add foo  feature/foo -> master  Alice  2020-01-02\n" +
+				"#3   Merged  Fix bar  fix/bar -> develop     -      2020-01-02\n",
+		},
+		{
+			name: "template",
+			f:    &ListFormat{Template: "{{.Number}}:{{.Status.Name}}:{{date .Updated}}"},
+			want: "12:Open:2020-01-02\n3:Merged:2020-01-02\n",
+		},
+		{
+			name: "color",
+			f:    &ListFormat{Color: true},
+			want: "\x1b[36m#12\x1b[0m  \x1b[32mOpen\x1b[0m    Add foo  feature/foo -> master  Alice  2020-01-02\n" +
+				"\x1b[36m#3\x1b[0m   \x1b[35mMerged\x1b[0m  Fix bar  fix/bar -> develop     -      2020-01-02\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := PrintPullRequests(&buf, prs, tt.f); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("PrintPullRequests() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintPullRequests_wide(t *testing.T) {
+	updated := time.Date(2020, 1, 2, 12, 0, 0, 0, time.Local)
+	prs := []*backlog.PullRequest{
+		{Number: 12, Summary: "ログインを直す", Base: "master", Branch: "fix", Updated: updated},
+		{Number: 3, Summary: "Add foo", Base: "master", Branch: "foo", Updated: updated},
+	}
+	want := "#12    ログインを直す  fix -> master  -  2020-01-02\n" +
+		"#3     Add foo         foo -> master  -  2020-01-02\n"
+	var buf bytes.Buffer
+	if err := PrintPullRequests(&buf, prs, &ListFormat{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("PrintPullRequests() = %q, want %q", got, want)
+	}
+}
+
+func TestBacklogRepository_ListPullRequests_pages(t *testing.T) {
+	defer func(n int) { pullRequestPageSize = n }(pullRequestPageSize)
+	pullRequestPageSize = 2
+	var offsets []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		offsets = append(offsets, q.Get("offset"))
+		offset, _ := strconv.Atoi(q.Get("offset"))
+		count, _ := strconv.Atoi(q.Get("count"))
+		var page []*backlog.PullRequest
+		for n := 10 - offset; n > 10-offset-count && n > 0; n-- {
+			base := "develop"
+			if n%3 == 0 {
+				base = "master"
+			}
+			page = append(page, &backlog.PullRequest{Number: n, Base: base})
+		}
+		_ = json.NewEncoder(w).Encode(page)
+	}))
+	defer s.Close()
+	b := &BacklogRepository{
+		api:        backlog.NewClient(s.URL).SetAPIKey("key"),
+		projectKey: "BAR",
+		repoName:   "baz",
+	}
+	got, err := b.ListPullRequests(&ListPullRequestsOptions{State: "all", Base: "master", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotNumbers []int
+	for _, pr := range got {
+		gotNumbers = append(gotNumbers, pr.Number)
+	}
+	if want := []int{9, 6}; !reflect.DeepEqual(gotNumbers, want) {
+		t.Errorf("BacklogRepository.ListPullRequests() = %v, want %v", gotNumbers, want)
+	}
+	if want := []string{"0", "2", "4"}; !reflect.DeepEqual(offsets, want) {
+		t.Errorf("BacklogRepository.ListPullRequests() offsets = %v, want %v", offsets, want)
+	}
+}
+
+func TestPrintPullRequests_json(t *testing.T) {
+	var buf bytes.Buffer
+	prs := []*backlog.PullRequest{{Number: 12, Summary: "Add foo"}}
+	if err := PrintPullRequests(&buf, prs, &ListFormat{JSON: true}); err != nil {
+		t.Fatal(err)
+	}
+	var got []*backlog.PullRequest
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Number != 12 || got[0].Summary != "Add foo" {
+		t.Errorf("PrintPullRequests() = %s", buf.String())
+	}
+}
+
+func Test_prCheckout_commands(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       *prCheckout
+		want    [][]string
+		wantErr bool
+	}{
+		{
+			name: "new branch",
+			p:    &prCheckout{remote: "origin", number: "3", ref: "refs/pull/3/head", branch: "feature/foo"},
+			want: [][]string{
+				{"fetch", "origin", "refs/pull/3/head"},
+				{"checkout", "-b", "feature/foo", "FETCH_HEAD"},
+				{"config", "branch.feature/foo.gitbPullRequest", "3"},
+			},
+		},
+		{
+			name: "new worktree",
+			p:    &prCheckout{remote: "origin", number: "3", ref: "refs/pull/3/head", branch: "pr/3", worktree: "../repo-pr-3"},
+			want: [][]string{
+				{"fetch", "origin", "refs/pull/3/head"},
+				{"worktree", "add", "-b", "pr/3", "../repo-pr-3", "FETCH_HEAD"},
+				{"config", "branch.pr/3.gitbPullRequest", "3"},
+			},
+		},
+		{
+			name: "fast-forward current branch",
+			p:    &prCheckout{remote: "origin", number: "3", ref: "refs/pull/3/head", branch: "pr/3", exists: true, tracking: "3", current: true},
+			want: [][]string{
+				{"fetch", "origin", "refs/pull/3/head"},
+				{"merge", "--ff-only", "FETCH_HEAD"},
+			},
+		},
+		{
+			name: "fast-forward other branch",
+			p:    &prCheckout{remote: "origin", number: "3", ref: "refs/pull/3/head", branch: "pr/3", exists: true, tracking: "3"},
+			want: [][]string{
+				{"fetch", "origin", "refs/pull/3/head:refs/heads/pr/3"},
+				{"checkout", "pr/3"},
+			},
+		},
+		{
+			name: "existing branch into worktree",
+			p:    &prCheckout{remote: "origin", number: "3", ref: "refs/pull/3/head", branch: "pr/3", exists: true, tracking: "3", worktree: "../repo-pr-3"},
+			want: [][]string{
+				{"fetch", "origin", "refs/pull/3/head:refs/heads/pr/3"},
+				{"worktree", "add", "../repo-pr-3", "pr/3"},
+			},
+		},
+		{
+			name:    "current branch into worktree",
+			p:       &prCheckout{remote: "origin", number: "3", ref: "refs/pull/3/head", branch: "pr/3", exists: true, tracking: "3", current: true, worktree: "../repo-pr-3"},
+			wantErr: true,
+		},
+		{
+			name:    "branch tracking other",
+			p:       &prCheckout{remote: "origin", number: "3", ref: "refs/pull/3/head", branch: "feature/foo", exists: true, tracking: ""},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.commands()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("prCheckout.commands() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("prCheckout.commands() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBacklogRepository_CheckoutPullRequest(t *testing.T) {
+	setenv(t, apiKeyEnv, "")
+	setenv(t, "XDG_CONFIG_HOME", t.TempDir())
+	var calls [][]string
+	b := &BacklogRepository{
+		repo: &RepositoryMock{
+			HeadNameFunc: func() string {
+				return "refs/heads/master"
+			},
+			RemoteNameFunc: func() string {
+				return "origin"
+			},
+			GitFunc: fakeGitCommand(&calls, func(args []string) bool {
+				// The branch does not exist yet.
+				return args[0] == "rev-parse"
+			}),
+		},
+		domain:     "backlog.com",
+		spaceKey:   "foo",
+		projectKey: "BAR",
+		repoName:   "baz",
+	}
+	if err := b.CheckoutPullRequest("#3", &CheckoutPullRequestOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"rev-parse", "--verify", "--quiet", "refs/heads/pr/3"},
+		{"fetch", "origin", "refs/pull/3/head"},
+		{"checkout", "-b", "pr/3", "FETCH_HEAD"},
+		{"config", "branch.pr/3.gitbPullRequest", "3"},
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("BacklogRepository.CheckoutPullRequest() ran git %v, want %v", calls, want)
+	}
+}
+
+func TestBacklogRepository_DiffPullRequest(t *testing.T) {
+	setenv(t, apiKeyEnv, "")
+	setenv(t, "XDG_CONFIG_HOME", t.TempDir())
+	var calls [][]string
+	b := &BacklogRepository{
+		repo: &RepositoryMock{
+			RemoteNameFunc: func() string {
+				return "origin"
+			},
+			LsRemoteFunc: func() (RefToHash, error) {
+				return RefToHash{
+					"refs/heads/master": "e73e35d0a86218a9624167110ff8e7fe42596234",
+					"refs/pull/3/head":  "2b2b5f9e8508a976096a50bd37c81c17ccdf7fb4",
+				}, nil
+			},
+			LocalRemoteRefsFunc: func() (RefToHash, error) {
+				return RefToHash{}, nil
+			},
+			FetchFunc: func(refs ...string) error {
+				return nil
+			},
+			GitFunc: fakeGitCommand(&calls, nil),
+		},
+		domain:     "backlog.com",
+		spaceKey:   "foo",
+		projectKey: "BAR",
+		repoName:   "baz",
+	}
+	if err := b.DiffPullRequest("3", "master", true, []string{"--", "main.go"}); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"diff", "--stat", "e73e35d0a86218a9624167110ff8e7fe42596234...2b2b5f9e8508a976096a50bd37c81c17ccdf7fb4", "--", "main.go"},
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("BacklogRepository.DiffPullRequest() ran git %v, want %v", calls, want)
+	}
+}
+
+func TestBacklogRepository_pullRequestRange(t *testing.T) {
+	setenv(t, apiKeyEnv, "")
+	setenv(t, "XDG_CONFIG_HOME", t.TempDir())
+	// local is the remote-tracking refs of the repository.
+	var local RefToHash
+	repo := &RepositoryMock{
+		HeadNameFunc: func() string {
+			return "refs/heads/patch-1"
+		},
+		RemoteNameFunc: func() string {
+			return "origin"
+		},
+		LsRemoteFunc: func() (RefToHash, error) {
+			return RefToHash{
+				"refs/heads/master":  "e73e35d0a86218a9624167110ff8e7fe42596234",
+				"refs/heads/develop": "117591be8e3911e4e34d28d9e4bad26d6aa00460",
+				"refs/heads/patch-1": "2b2b5f9e8508a976096a50bd37c81c17ccdf7fb4",
+				"refs/pull/3/head":   "2b2b5f9e8508a976096a50bd37c81c17ccdf7fb4",
+			}, nil
+		},
+		LocalRemoteRefsFunc: func() (RefToHash, error) {
+			return local, nil
+		},
+		DefaultBranchFunc: func() (string, error) {
+			return "master", nil
+		},
+		FetchFunc: func(refs ...string) error {
+			return nil
+		},
+	}
+	tests := []struct {
+		name     string
+		id       string
+		base     string
+		api      *backlog.Client
+		local    RefToHash
+		online   bool
+		wantBase string
+		wantHead string
+		wantErr  bool
+	}{
+		{
+			name:     "current branch",
+			wantBase: "e73e35d0a86218a9624167110ff8e7fe42596234",
+			wantHead: "2b2b5f9e8508a976096a50bd37c81c17ccdf7fb4",
+		},
+		{
+			name:     "base of the pull request",
+			id:       "3",
+			api:      newAPIServer(t, map[string]interface{}{"GET /api/v2/projects/BAR/git/repositories/baz/pullRequests/3": &backlog.PullRequest{Number: 3, Base: "develop"}}, nil),
+			wantBase: "117591be8e3911e4e34d28d9e4bad26d6aa00460",
+			wantHead: "2b2b5f9e8508a976096a50bd37c81c17ccdf7fb4",
+		},
+		{
+			name:     "given base",
+			id:       "#3",
+			base:     "develop",
+			wantBase: "117591be8e3911e4e34d28d9e4bad26d6aa00460",
+			wantHead: "2b2b5f9e8508a976096a50bd37c81c17ccdf7fb4",
+		},
+		{
+			name: "remote-tracking refs",
+			id:   "3",
+			base: "develop",
+			local: RefToHash{
+				"refs/heads/develop": "dc1e4b6f0e3b8ab6bf7a8d1e4e4ba6ac5b9a4b6e",
+				"refs/pull/3/head":   "8f5b4f2c5d0b3b0f4d6b2a3e1c7d9e8f0a1b2c3d",
+			},
+			wantBase: "dc1e4b6f0e3b8ab6bf7a8d1e4e4ba6ac5b9a4b6e",
+			wantHead: "8f5b4f2c5d0b3b0f4d6b2a3e1c7d9e8f0a1b2c3d",
+		},
+		{
+			name: "remote-tracking refs without the base",
+			id:   "3",
+			base: "develop",
+			local: RefToHash{
+				"refs/pull/3/head": "8f5b4f2c5d0b3b0f4d6b2a3e1c7d9e8f0a1b2c3d",
+			},
+			wantBase: "117591be8e3911e4e34d28d9e4bad26d6aa00460",
+			wantHead: "2b2b5f9e8508a976096a50bd37c81c17ccdf7fb4",
+		},
+		{
+			name: "online",
+			id:   "3",
+			base: "develop",
+			local: RefToHash{
+				"refs/heads/develop": "dc1e4b6f0e3b8ab6bf7a8d1e4e4ba6ac5b9a4b6e",
+				"refs/pull/3/head":   "8f5b4f2c5d0b3b0f4d6b2a3e1c7d9e8f0a1b2c3d",
+			},
+			online:   true,
+			wantBase: "117591be8e3911e4e34d28d9e4bad26d6aa00460",
+			wantHead: "2b2b5f9e8508a976096a50bd37c81c17ccdf7fb4",
+		},
+		{
+			name:    "unknown pull request",
+			id:      "4",
+			wantErr: true,
+		},
+		{
+			name:    "unknown base",
+			id:      "3",
+			base:    "release",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local = tt.local
+			b := &BacklogRepository{
+				repo:       repo,
+				api:        tt.api,
+				online:     tt.online,
+				domain:     "backlog.com",
+				spaceKey:   "foo",
+				projectKey: "BAR",
+				repoName:   "baz",
+			}
+			gotBase, gotHead, err := b.pullRequestRange(tt.id, tt.base)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BacklogRepository.pullRequestRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if gotBase != tt.wantBase {
+				t.Errorf("BacklogRepository.pullRequestRange() gotBase = %v, want %v", gotBase, tt.wantBase)
+			}
+			if gotHead != tt.wantHead {
+				t.Errorf("BacklogRepository.pullRequestRange() gotHead = %v, want %v", gotHead, tt.wantHead)
+			}
+		})
+	}
+}
+
+func Test_prDiffArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		stat bool
+		args []string
+		want []string
+	}{
+		{
+			name: "diff",
+			want: []string{"diff", "base...head"},
+		},
+		{
+			name: "stat",
+			stat: true,
+			args: []string{"--name-only"},
+			want: []string{"diff", "--stat", "--name-only", "base...head"},
+		},
+		{
+			name: "paths",
+			args: []string{"-w", "--", "main.go"},
+			want: []string{"diff", "-w", "base...head", "--", "main.go"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := prDiffArgs("base", "head", tt.stat, tt.args); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("prDiffArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBacklogRepository_FetchPullRequests(t *testing.T) {
+	var calls [][]string
+	b := &BacklogRepository{
+		repo: &RepositoryMock{
+			RemoteNameFunc: func() string {
+				return "origin"
+			},
+			GitFunc: fakeGitCommand(&calls, nil),
+		},
+	}
+	if err := b.FetchPullRequests(); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"config", "--get-all", "remote.origin.fetch"},
+		{"config", "--add", "remote.origin.fetch", "+refs/pull/*/head:refs/remotes/origin/pr/*"},
+		{"fetch", "origin"},
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("BacklogRepository.FetchPullRequests() ran git %v, want %v", calls, want)
+	}
+}
+
+func Test_prFetchRefspec(t *testing.T) {
+	want := "+refs/pull/*/head:refs/remotes/origin/pr/*"
+	if got := prFetchRefspec("origin"); got != want {
+		t.Errorf("prFetchRefspec() = %v, want %v", got, want)
+	}
+}
+
+func Test_prDiffFlags(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantBase   string
+		wantOnline bool
+		wantHelp   bool
+		wantRest   []string
+		wantErr    bool
+	}{
+		{
+			name:     "git diff options",
+			args:     []string{"3", "--stat", "-w", "--", "main.go"},
+			wantRest: []string{"3", "--stat", "-w", "--", "main.go"},
+		},
+		{
+			name:       "own flags",
+			args:       []string{"--online", "3", "-b", "develop", "--name-only"},
+			wantBase:   "develop",
+			wantOnline: true,
+			wantRest:   []string{"3", "--name-only"},
+		},
+		{
+			name:     "base with =",
+			args:     []string{"--base=develop", "--", "--online"},
+			wantBase: "develop",
+			wantRest: []string{"--", "--online"},
+		},
+		{
+			name:     "help",
+			args:     []string{"--help"},
+			wantHelp: true,
+		},
+		{
+			name:    "base without branch",
+			args:    []string{"3", "--base"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, online, help, rest, err := prDiffFlags(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("prDiffFlags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if base != tt.wantBase || online != tt.wantOnline || help != tt.wantHelp || !reflect.DeepEqual(rest, tt.wantRest) {
+				t.Errorf("prDiffFlags() = %v, %v, %v, %v, want %v, %v, %v, %v",
+					base, online, help, rest, tt.wantBase, tt.wantOnline, tt.wantHelp, tt.wantRest)
+			}
+		})
+	}
+}
+
+func TestBacklogRepository_verifyCommit(t *testing.T) {
+	_, commit := newGitRepo(t)
+	commit("a.txt", "a\n", "first")
+	b := &BacklogRepository{repo: &RepositoryMock{GitFunc: workingDirGit}}
+	head, err := b.runGit("rev-parse", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := b.verifyCommit("master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.TrimSpace(head); got != want {
+		t.Errorf("BacklogRepository.verifyCommit() = %v, want %v", got, want)
+	}
+	if _, err := b.verifyCommit("unknown"); err == nil {
+		t.Error("BacklogRepository.verifyCommit() error = nil, want an error of the unknown commit")
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// BacklogRemote is a remote repository hosted on Backlog.
+type BacklogRemote struct {
+	Protocol   string
+	Host       string
+	BaseURL    string
+	SpaceKey   string
+	Domain     string
+	ProjectKey string
+	RepoName   string
+}
+
+// ErrNotBacklogRemote is returned when a remote URL does not point to a Backlog repository.
+type ErrNotBacklogRemote struct {
+	URL    string
+	Reason string
+}
+
+func (e *ErrNotBacklogRemote) Error() string {
+	return fmt.Sprintf("%s is not a Backlog remote: %s", e.URL, e.Reason)
+}
+
+// IsNotBacklogRemote reports whether err is caused by ErrNotBacklogRemote.
+func IsNotBacklogRemote(err error) bool {
+	_, ok := errors.Cause(err).(*ErrNotBacklogRemote)
+	return ok
+}
+
+// scpLikeURL matches scp-style URLs such as foo@foo.git.backlog.com:/BAR/baz.git
+var scpLikeURL = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]{2,}):(.*)$`)
+
+// ParseBacklogRemote parses a remote URL of Backlog in HTTPS, ssh:// or
+// scp-style form. Hosts are consulted for Backlog Enterprise and custom domains.
+func ParseBacklogRemote(rawURL string, hosts Hosts) (*BacklogRemote, error) {
+	protocol, host, p, err := splitRemoteURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	r := &BacklogRemote{
+		Protocol: protocol,
+		Host:     host,
+	}
+	if c, ok := hosts.Lookup(host); ok {
+		r.BaseURL = c.BaseURL
+		r.SpaceKey, r.Domain = extractSpaceKeyAndDomain(host)
+		if u, err := url.Parse(c.BaseURL); err == nil && u.Hostname() != "" {
+			r.Domain = u.Hostname()
+		}
+		if c.SpaceKey != "" {
+			r.SpaceKey = c.SpaceKey
+		}
+		p = strings.TrimPrefix(p, c.PathPrefix)
+	} else if isBacklogHost(host) {
+		r.SpaceKey, r.Domain = extractSpaceKeyAndDomain(host)
+	} else {
+		return nil, &ErrNotBacklogRemote{URL: rawURL, Reason: "unknown host " + host}
+	}
+	r.ProjectKey, r.RepoName, err = extractProjectKeyAndRepoName(p)
+	if err != nil {
+		return nil, &ErrNotBacklogRemote{URL: rawURL, Reason: err.Error()}
+	}
+	return r, nil
+}
+
+func splitRemoteURL(rawURL string) (protocol, host, path string, err error) {
+	if strings.Contains(rawURL, "://") {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return "", "", "", &ErrNotBacklogRemote{URL: rawURL, Reason: err.Error()}
+		}
+		switch u.Scheme {
+		case "https", "http", "ssh":
+		default:
+			return "", "", "", &ErrNotBacklogRemote{URL: rawURL, Reason: "unsupported protocol " + u.Scheme}
+		}
+		return u.Scheme, u.Hostname(), u.Path, nil
+	}
+	matches := scpLikeURL.FindStringSubmatch(rawURL)
+	if len(matches) < 3 {
+		return "", "", "", &ErrNotBacklogRemote{URL: rawURL, Reason: "local path"}
+	}
+	return "ssh", matches[1], "/" + strings.TrimPrefix(matches[2], "/"), nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseBacklogRemote(t *testing.T) {
+	hosts := Hosts{
+		"backlog.corp.example.com": HostConfig{
+			BaseURL:    "https://backlog.corp.example.com/backlog",
+			SpaceKey:   "corp",
+			PathPrefix: "/backlog",
+		},
+	}
+	type args struct {
+		rawURL string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *BacklogRemote
+		wantErr bool
+	}{
+		{
+			args: args{"https://foo.backlog.com/git/BAR/baz.git"},
+			want: &BacklogRemote{
+				Protocol:   "https",
+				Host:       "foo.backlog.com",
+				SpaceKey:   "foo",
+				Domain:     "backlog.com",
+				ProjectKey: "BAR",
+				RepoName:   "baz",
+			},
+		},
+		{
+			args: args{"foo@foo.git.backlog.jp:/BAR/baz.git"},
+			want: &BacklogRemote{
+				Protocol:   "ssh",
+				Host:       "foo.git.backlog.jp",
+				SpaceKey:   "foo",
+				Domain:     "backlog.jp",
+				ProjectKey: "BAR",
+				RepoName:   "baz",
+			},
+		},
+		{
+			args: args{"ssh://foo@foo.git.backlogtool.com/BAR/baz.git"},
+			want: &BacklogRemote{
+				Protocol:   "ssh",
+				Host:       "foo.git.backlogtool.com",
+				SpaceKey:   "foo",
+				Domain:     "backlogtool.com",
+				ProjectKey: "BAR",
+				RepoName:   "baz",
+			},
+		},
+		{
+			args: args{"ssh://git@backlog.corp.example.com:2222/backlog/BAR/baz.git"},
+			want: &BacklogRemote{
+				Protocol:   "ssh",
+				Host:       "backlog.corp.example.com",
+				BaseURL:    "https://backlog.corp.example.com/backlog",
+				SpaceKey:   "corp",
+				Domain:     "backlog.corp.example.com",
+				ProjectKey: "BAR",
+				RepoName:   "baz",
+			},
+		},
+		{
+			args:    args{"git@github.com:foo/bar.git"},
+			wantErr: true,
+		},
+		{
+			args:    args{"/path/to/repo.git"},
+			wantErr: true,
+		},
+		{
+			args:    args{"file:///path/to/repo.git"},
+			wantErr: true,
+		},
+		{
+			args:    args{"https://foo.backlog.com/git/BAR/baz/qux.git"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseBacklogRemote(tt.args.rawURL, hosts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseBacklogRemote() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil && !IsNotBacklogRemote(err) {
+				t.Errorf("ParseBacklogRemote() error = %v, want ErrNotBacklogRemote", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseBacklogRemote() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
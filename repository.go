@@ -4,29 +4,38 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
 	"regexp"
-	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/pkg/errors"
+	"github.com/vvatanabe/gitb/internal/backlog"
 	"gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/plumbing"
-	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 )
 
 type Repository interface {
 	HeadName() string
 	HeadShortName() string
-	RemoteEndpointHost() string
-	RemoteEndpointPath() string
+	RemoteName() string
+	RemoteURL() string
 	RootDirectory() string
+	HeadHash() string
 	LsRemote() (RefToHash, error)
+	DefaultBranch() (string, error)
+	CommitsSince(base string) ([]*Commit, error)
+	Push(branch string) error
+	Fetch(refs ...string) error
+	LocalRemoteRefs() (RefToHash, error)
+	Git(args ...string) *exec.Cmd
 }
 
-func OpenRepository(path string) (Repository, error) {
+func OpenRepository(path, remoteName string, hosts Hosts) (Repository, error) {
 	repo, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{
 		DetectDotGit: true,
 	})
@@ -37,30 +46,96 @@ func OpenRepository(path string) (Repository, error) {
 	if err != nil {
 		return nil, err
 	}
-	remote, err := repo.Remote("origin")
+	if remoteName == "" {
+		remoteName, err = detectBacklogRemote(repo, hosts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	remote, err := repo.Remote(remoteName)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrapf(err, "could not open remote %q", remoteName)
 	}
 	cfg := remote.Config()
 	if len(cfg.URLs) == 0 {
 		return nil, errors.New("could not find remote URL")
 	}
-	u := cfg.URLs[0]
-	ep, err := transport.NewEndpoint(u)
-	if err != nil {
-		return nil, err
-	}
 	return &repository{
-		repo: repo,
-		head: head,
-		ep:   ep,
+		repo:       repo,
+		head:       head,
+		remoteName: remoteName,
+		remoteURL:  cfg.URLs[0],
 	}, nil
 }
 
+const (
+	defaultRemoteName = "origin"
+	remoteConfigKey   = "gitb.remote"
+)
+
+// backlogDomains are the domains of Backlog's spaces. Remote hosts are
+// formed as <space>.<domain> or <space>.git.<domain> for SSH.
+var backlogDomains = []string{
+	"backlog.com",
+	"backlog.jp",
+	"backlogtool.com",
+}
+
+func isBacklogHost(host string) bool {
+	for _, d := range backlogDomains {
+		if strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+func detectBacklogRemote(repo *git.Repository, hosts Hosts) (string, error) {
+	remotes, err := repo.Remotes()
+	if err != nil {
+		return "", err
+	}
+	nameToURL := make(map[string]string)
+	for _, r := range remotes {
+		cfg := r.Config()
+		if len(cfg.URLs) == 0 {
+			continue
+		}
+		nameToURL[cfg.Name] = cfg.URLs[0]
+	}
+	return chooseBacklogRemote(nameToURL, hosts)
+}
+
+// chooseBacklogRemote picks the remote whose URL points to Backlog.
+// When several remotes point to Backlog, "origin" is preferred and
+// any other combination is reported as ambiguous.
+func chooseBacklogRemote(nameToURL map[string]string, hosts Hosts) (string, error) {
+	var candidates []string
+	for name, u := range nameToURL {
+		if _, err := ParseBacklogRemote(u, hosts); err == nil {
+			candidates = append(candidates, name)
+		}
+	}
+	sort.Strings(candidates)
+	switch len(candidates) {
+	case 0:
+		return "", errors.New("could not find a Backlog remote. specify it with --remote or git config " + remoteConfigKey)
+	case 1:
+		return candidates[0], nil
+	}
+	for _, name := range candidates {
+		if name == defaultRemoteName {
+			return name, nil
+		}
+	}
+	return "", errors.Errorf("found multiple Backlog remotes %v. choose one with --remote or git config %s", candidates, remoteConfigKey)
+}
+
 type repository struct {
-	repo *git.Repository
-	head *plumbing.Reference
-	ep   *transport.Endpoint
+	repo       *git.Repository
+	head       *plumbing.Reference
+	remoteName string
+	remoteURL  string
 }
 
 func (r repository) HeadName() string {
@@ -71,12 +146,12 @@ func (r repository) HeadShortName() string {
 	return r.head.Name().Short()
 }
 
-func (r repository) RemoteEndpointHost() string {
-	return r.ep.Host
+func (r repository) RemoteName() string {
+	return r.remoteName
 }
 
-func (r repository) RemoteEndpointPath() string {
-	return r.ep.Path
+func (r repository) RemoteURL() string {
+	return r.remoteURL
 }
 
 func (r repository) RootDirectory() string {
@@ -87,8 +162,18 @@ func (r repository) RootDirectory() string {
 	return wt.Filesystem.Root()
 }
 
+// Git returns the git command run in the root directory of the repository,
+// so that it does not depend on the working directory of the process.
+func (r repository) Git(args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	if wt, err := r.repo.Worktree(); err == nil {
+		cmd.Dir = wt.Filesystem.Root()
+	}
+	return cmd
+}
+
 func (r repository) LsRemote() (RefToHash, error) {
-	cmd := exec.Command("git", "ls-remote", "-q")
+	cmd := r.Git("ls-remote", "-q", r.remoteName)
 	out, err := cmd.Output()
 	if err != nil {
 		return nil, err
@@ -96,53 +181,211 @@ func (r repository) LsRemote() (RefToHash, error) {
 	return toRefToHash(out), nil
 }
 
+func (r repository) HeadHash() string {
+	return r.head.Hash().String()
+}
+
+// DefaultBranch returns the default branch of the remote, from the
+// remote-tracking HEAD or by asking the remote.
+func (r repository) DefaultBranch() (string, error) {
+	out, err := r.Git("symbolic-ref", "--short", "refs/remotes/"+r.remoteName+"/HEAD").Output()
+	if err == nil {
+		return strings.TrimPrefix(strings.TrimSpace(string(out)), r.remoteName+"/"), nil
+	}
+	out, err = r.Git("ls-remote", "--symref", r.remoteName, "HEAD").Output()
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "ref: ") && strings.HasSuffix(line, "\tHEAD") {
+			ref := strings.TrimSuffix(strings.TrimPrefix(line, "ref: "), "\tHEAD")
+			return strings.TrimPrefix(ref, refBranchPrefix), nil
+		}
+	}
+	return "", errors.Errorf("could not find the default branch of %s", r.remoteName)
+}
+
+// CommitsSince returns the commits of HEAD since the merge-base with base of
+// the remote, oldest first.
+func (r repository) CommitsSince(base string) ([]*Commit, error) {
+	out, err := r.Git("merge-base", "HEAD", "refs/remotes/"+r.remoteName+"/"+base).Output()
+	if err != nil {
+		out, err = r.Git("merge-base", "HEAD", base).Output()
+		if err != nil {
+			return nil, errors.Errorf("could not find the merge-base with %s", base)
+		}
+	}
+	mergeBase := strings.TrimSpace(string(out))
+	out, err = r.Git("log", "--reverse", "--format="+commitFormat, mergeBase+"..HEAD").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseCommits(string(out)), nil
+}
+
+func (r repository) Push(branch string) error {
+	cmd := r.Git("push", "--set-upstream", r.remoteName, "HEAD:"+refBranchPrefix+branch)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// LocalRemoteRefs returns the refs of the remote as of the last fetch, from
+// its remote-tracking refs. They are named as in the remote like LsRemote:
+// refs/remotes/<remote>/pr/<id>, fetched by `gitb pr fetch`, is returned as
+// refs/pull/<id>/head, and the others as branches.
+func (r repository) LocalRemoteRefs() (RefToHash, error) {
+	refs, err := r.repo.References()
+	if err != nil {
+		return nil, err
+	}
+	defer refs.Close()
+	prefix := "refs/remotes/" + r.remoteName + "/"
+	refToHash := make(RefToHash)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().String()
+		if ref.Type() != plumbing.HashReference || !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+		refToHash[remoteRefName(strings.TrimPrefix(name, prefix))] = ref.Hash().String()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refToHash, nil
+}
+
+// remoteRefName returns the name in the remote of the remote-tracking ref
+// refs/remotes/<remote>/<name>.
+func remoteRefName(name string) string {
+	if id := strings.TrimPrefix(name, prRemoteTrackingPrefix); id != name {
+		if _, err := strconv.Atoi(id); err == nil {
+			return refPullRequestPrefix + id + refPullRequestSuffix
+		}
+	}
+	return refBranchPrefix + name
+}
+
+// Fetch fetches the objects of refs from the remote without updating local refs.
+func (r repository) Fetch(refs ...string) error {
+	cmd := r.Git(append([]string{"fetch", "--quiet", r.remoteName}, refs...)...)
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// Commit is a commit listed by git log.
+type Commit struct {
+	Hash    string
+	Subject string
+	Body    string
+}
+
+// commitFormat separates the fields with NUL and the commits with RS.
+const commitFormat = "%H%x00%s%x00%b%x1e"
+
+func parseCommits(s string) []*Commit {
+	var commits []*Commit
+	for _, record := range strings.Split(s, "\x1e") {
+		fields := strings.SplitN(strings.TrimLeft(record, "\n"), "\x00", 3)
+		if len(fields) < 3 {
+			continue
+		}
+		commits = append(commits, &Commit{
+			Hash:    fields[0],
+			Subject: fields[1],
+			Body:    strings.TrimSpace(fields[2]),
+		})
+	}
+	return commits
+}
+
 func toRefToHash(b []byte) RefToHash {
 	refToHash := make(RefToHash)
 	remotes := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
 	for _, v := range remotes {
 		delimited := strings.Split(v, "\t")
-		hash := delimited[0]
-		ref := delimited[1]
-		refToHash[ref] = hash
+		if len(delimited) < 2 {
+			continue
+		}
+		refToHash[delimited[1]] = delimited[0]
 	}
 	return refToHash
 }
 
-func NewBacklogRepository(repo Repository) *BacklogRepository {
-	spaceKey, domain := extractSpaceKeyAndDomain(repo.RemoteEndpointHost())
-	projectKey, repoName := extractProjectKeyAndRepoName(repo.RemoteEndpointPath())
-	return &BacklogRepository{
-		openBrowser: openBrowser,
-		repo:        repo,
-		domain:      domain,
-		spaceKey:    spaceKey,
-		projectKey:  projectKey,
-		repoName:    repoName,
+func NewBacklogRepository(repo Repository, cfg *Config) (*BacklogRepository, error) {
+	remote, err := ParseBacklogRemote(repo.RemoteURL(), cfg.Hosts())
+	if err != nil {
+		return nil, err
 	}
+	if s := cfg.Get("space"); s != "" {
+		remote.SpaceKey = s
+	}
+	return &BacklogRepository{
+		openURL:    defaultURLSink(cfg),
+		repo:       repo,
+		config:     cfg,
+		baseURL:    remote.BaseURL,
+		domain:     remote.Domain,
+		spaceKey:   remote.SpaceKey,
+		projectKey: remote.ProjectKey,
+		repoName:   remote.RepoName,
+	}, nil
 }
 
 func extractSpaceKeyAndDomain(host string) (spaceKey, domain string) {
 	delimitedHost := strings.Split(host, ".")
 	spaceKey = delimitedHost[0]
+	if len(delimitedHost) < 2 {
+		return spaceKey, host
+	}
 	domain = strings.Join(delimitedHost[len(delimitedHost)-2:], ".")
 	return
 }
 
-func extractProjectKeyAndRepoName(path string) (projectKey, repoName string) {
-	epPath := strings.TrimPrefix(path, "/git")
-	delimitedPath := strings.Split(epPath, "/")
-	projectKey = delimitedPath[1]
-	repoName = strings.TrimSuffix(delimitedPath[2], ".git")
+func extractProjectKeyAndRepoName(path string) (projectKey, repoName string, err error) {
+	var delimitedPath []string
+	for _, v := range strings.Split(path, "/") {
+		if v != "" {
+			delimitedPath = append(delimitedPath, v)
+		}
+	}
+	if len(delimitedPath) == 3 && delimitedPath[0] == "git" {
+		delimitedPath = delimitedPath[1:]
+	}
+	if len(delimitedPath) != 2 || delimitedPath[1] == ".git" {
+		return "", "", errors.Errorf("path %s is not formed as /<PROJECT>/<REPO>.git", path)
+	}
+	projectKey = delimitedPath[0]
+	repoName = strings.TrimSuffix(delimitedPath[1], ".git")
 	return
 }
 
 type BacklogRepository struct {
-	openBrowser func(url string) error
-	repo        Repository
-	domain      string
-	spaceKey    string
-	projectKey  string
-	repoName    string
+	openURL URLSink
+	api     *backlog.Client
+	// online makes pull requests looked up in the remote, not in the
+	// remote-tracking refs.
+	online     bool
+	repo       Repository
+	config     *Config
+	baseURL    string
+	domain     string
+	spaceKey   string
+	projectKey string
+	repoName   string
+}
+
+func (b *BacklogRepository) urlBuilder() *BacklogURLBuilder {
+	return NewBacklogURLBuilder(b.domain, b.spaceKey).
+		SetBaseURL(b.baseURL).
+		SetProjectKey(b.projectKey).
+		SetRepoName(b.repoName)
+}
+
+// NewAPIClient returns a client of Backlog API for the space of the repository.
+func (b *BacklogRepository) NewAPIClient(apiKey string) *backlog.Client {
+	return backlog.NewClient(b.urlBuilder().BaseURL()).SetAPIKey(apiKey)
 }
 
 func (b *BacklogRepository) OpenObject(absPath string, isDirectory bool, line string) error {
@@ -162,68 +405,44 @@ func (b *BacklogRepository) OpenObject(absPath string, isDirectory bool, line st
 		}
 	}
 	relPath := strings.TrimPrefix(absPath[len(root):], "/")
-	return b.openBrowser(NewBacklogURLBuilder(b.domain, b.spaceKey).
-		SetProjectKey(b.projectKey).
-		SetRepoName(b.repoName).
-		ObjectURL(b.repo.HeadShortName(), relPath, isDirectory, line))
+	return b.openURL(b.urlBuilder().ObjectURL(b.repo.HeadShortName(), relPath, isDirectory, line))
 }
 
 func (b *BacklogRepository) OpenRepositoryList() error {
-	return b.openBrowser(NewBacklogURLBuilder(b.domain, b.spaceKey).
-		SetProjectKey(b.projectKey).
-		SetRepoName(b.repoName).
-		GitBaseURL())
+	return b.openURL(b.urlBuilder().GitBaseURL())
 }
 
 func (b *BacklogRepository) OpenTree(refOrHash string) error {
 	if refOrHash == "" {
 		refOrHash = b.repo.HeadShortName()
 	}
-	return b.openBrowser(NewBacklogURLBuilder(b.domain, b.spaceKey).
-		SetProjectKey(b.projectKey).
-		SetRepoName(b.repoName).
-		TreeURL(refOrHash))
+	return b.openURL(b.urlBuilder().TreeURL(refOrHash))
 }
 
 func (b *BacklogRepository) OpenHistory(refOrHash string) error {
 	if refOrHash == "" {
 		refOrHash = b.repo.HeadShortName()
 	}
-	return b.openBrowser(NewBacklogURLBuilder(b.domain, b.spaceKey).
-		SetProjectKey(b.projectKey).
-		SetRepoName(b.repoName).
-		HistoryURL(refOrHash))
+	return b.openURL(b.urlBuilder().HistoryURL(refOrHash))
 }
 
 func (b *BacklogRepository) OpenCommit(hash string) error {
-	return b.openBrowser(NewBacklogURLBuilder(b.domain, b.spaceKey).
-		SetProjectKey(b.projectKey).
-		SetRepoName(b.repoName).
-		CommitURL(hash))
+	return b.openURL(b.urlBuilder().CommitURL(hash))
 }
 
 func (b *BacklogRepository) OpenNetwork(refOrHash string) error {
 	if refOrHash == "" {
 		refOrHash = b.repo.HeadShortName()
 	}
-	return b.openBrowser(NewBacklogURLBuilder(b.domain, b.spaceKey).
-		SetProjectKey(b.projectKey).
-		SetRepoName(b.repoName).
-		NetworkURL(refOrHash))
+	return b.openURL(b.urlBuilder().NetworkURL(refOrHash))
 }
 
 func (b *BacklogRepository) OpenBranchList() error {
-	return b.openBrowser(NewBacklogURLBuilder(b.domain, b.spaceKey).
-		SetProjectKey(b.projectKey).
-		SetRepoName(b.repoName).
-		BranchListURL())
+	return b.openURL(b.urlBuilder().BranchListURL())
 }
 
 func (b *BacklogRepository) OpenTagList() error {
-	return b.openBrowser(NewBacklogURLBuilder(b.domain, b.spaceKey).
-		SetProjectKey(b.projectKey).
-		SetRepoName(b.repoName).
-		TagListURL())
+	return b.openURL(b.urlBuilder().TagListURL())
 }
 
 func (b *BacklogRepository) OpenPullRequestList(status string) error {
@@ -231,10 +450,7 @@ func (b *BacklogRepository) OpenPullRequestList(status string) error {
 	if err != nil {
 		return err
 	}
-	return b.openBrowser(NewBacklogURLBuilder(b.domain, b.spaceKey).
-		SetProjectKey(b.projectKey).
-		SetRepoName(b.repoName).
-		PullRequestListURL(s.Int()))
+	return b.openURL(b.urlBuilder().PullRequestListURL(s.Int()))
 }
 
 type PRStatus int
@@ -269,14 +485,11 @@ func PRStatusFromString(s string) (status PRStatus, err error) {
 }
 
 func (b *BacklogRepository) OpenPullRequestByID(id string) error {
-	return b.openBrowser(NewBacklogURLBuilder(b.domain, b.spaceKey).
-		SetProjectKey(b.projectKey).
-		SetRepoName(b.repoName).
-		PullRequestURL(id))
+	return b.openURL(b.urlBuilder().PullRequestURL(id))
 }
 
 func (b *BacklogRepository) OpenPullRequest() error {
-	id, err := b.findPullRequestIDFromRemote(b.repo.HeadName())
+	id, err := b.findPullRequestID(b.repo.HeadName())
 	if err != nil {
 		return err
 	}
@@ -285,42 +498,233 @@ func (b *BacklogRepository) OpenPullRequest() error {
 
 const (
 	refPrefix            = "refs/"
+	refBranchPrefix      = refPrefix + "heads/"
 	refPullRequestPrefix = refPrefix + "pull/"
 	refPullRequestSuffix = "/head"
+	// prRemoteTrackingPrefix is the prefix of the remote-tracking refs of
+	// pull requests under refs/remotes/<remote>/.
+	prRemoteTrackingPrefix = "pr/"
 )
 
 type RefToHash map[string]string
 
-func (b *BacklogRepository) findPullRequestIDFromRemote(ref string) (string, error) {
+// PullRequestCandidate is a pull request related to a branch.
+type PullRequestCandidate struct {
+	Number  int
+	Summary string
+	// Status is empty when the pull request is found without Backlog API.
+	Status string
+	Open   bool
+}
 
-	refToHash, err := b.repo.LsRemote()
+// findPullRequestID resolves the pull request of ref. Open pull requests are
+// preferred to closed or merged ones, and the user chooses one when several
+// are open.
+func (b *BacklogRepository) findPullRequestID(ref string) (string, error) {
+	candidates, err := b.findPullRequests(ref)
+	if err != nil {
+		return "", err
+	}
+	pr, err := selectPullRequest(candidates, promptPullRequest(os.Stdin, os.Stderr))
 	if err != nil {
 		return "", err
 	}
+	return strconv.Itoa(pr.Number), nil
+}
+
+// pullRequestLookupLimit is the number of the recent pull requests of each
+// state in which findPullRequests looks for the branch.
+const pullRequestLookupLimit = 200
+
+// findPullRequests returns the pull requests of ref, newest first. They are
+// matched by the source branch with Backlog API, or by the hash of the
+// branch in the remote otherwise.
+func (b *BacklogRepository) findPullRequests(ref string) ([]*PullRequestCandidate, error) {
+	if !strings.HasPrefix(ref, refBranchPrefix) {
+		return nil, errors.New("not on a branch")
+	}
+	var candidates []*PullRequestCandidate
+	if client, err := b.APIClient(); err == nil {
+		branch := strings.TrimPrefix(ref, refBranchPrefix)
+		// Open pull requests are looked up first, as the branch of them is
+		// usually the one wanted. Closed and merged ones are looked up in the
+		// recent ones only when none is open.
+		for _, statusIDs := range [][]int{
+			{backlog.PullRequestStatusOpen},
+			{backlog.PullRequestStatusClosed, backlog.PullRequestStatusMerged},
+		} {
+			list := &backlog.PullRequestListOptions{StatusIDs: statusIDs, Limit: pullRequestLookupLimit}
+			err := b.eachPullRequest(context.Background(), client, list, func(pr *backlog.PullRequest) bool {
+				if pr.Branch == branch {
+					c := &PullRequestCandidate{Number: pr.Number, Summary: pr.Summary}
+					if pr.Status != nil {
+						c.Status = pr.Status.Name
+						c.Open = pr.Status.ID == backlog.PullRequestStatusOpen
+					}
+					candidates = append(candidates, c)
+				}
+				return true
+			})
+			if err != nil {
+				return nil, err
+			}
+			if len(candidates) > 0 {
+				break
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		if refToHash, ok := b.localRemoteRefs(ref); ok {
+			candidates = pullRequestsOfRef(refToHash, ref)
+		}
+	}
+	if len(candidates) == 0 {
+		// A pull request opened after `gitb pr fetch` is only in the remote.
+		refToHash, err := b.repo.LsRemote()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := refToHash[ref]; !ok {
+			return nil, errors.New("not found a current branch in remote")
+		}
+		candidates = pullRequestsOfRef(refToHash, ref)
+	}
+	if len(candidates) == 0 {
+		return nil, errors.New("not found a pull request related to current branch")
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Number > candidates[j].Number
+	})
+	return candidates, nil
+}
 
+// pullRequestsOfRef returns the pull requests in refToHash whose head is at
+// the same commit as ref.
+func pullRequestsOfRef(refToHash RefToHash, ref string) []*PullRequestCandidate {
 	targetHash, ok := refToHash[ref]
 	if !ok {
-		return "", errors.New("not found a current branch in remote")
+		return nil
 	}
-
-	var prIDs []string
+	var candidates []*PullRequestCandidate
 	for ref, hash := range refToHash {
-		if !isPRRef(ref) {
+		if !isPRRef(ref) || hash != targetHash {
 			continue
 		}
-		if hash != targetHash {
+		n, err := strconv.Atoi(extractPRID(ref))
+		if err != nil {
 			continue
 		}
-		prIDs = append(prIDs, extractPRID(ref))
+		candidates = append(candidates, &PullRequestCandidate{Number: n, Open: true})
+	}
+	return candidates
+}
+
+// remoteRefs returns the refs of the remote. The remote-tracking refs are
+// used instead of asking the remote when they have all of refs and the pull
+// requests fetched by `gitb pr fetch`, unless b.online is true.
+func (b *BacklogRepository) remoteRefs(refs ...string) (RefToHash, error) {
+	if refToHash, ok := b.localRemoteRefs(refs...); ok {
+		return refToHash, nil
+	}
+	return b.repo.LsRemote()
+}
+
+// localRemoteRefs returns the remote-tracking refs, and whether they can be
+// used for refs as remoteRefs says.
+func (b *BacklogRepository) localRemoteRefs(refs ...string) (RefToHash, bool) {
+	if b.online {
+		return nil, false
+	}
+	refToHash, err := b.repo.LocalRemoteRefs()
+	if err != nil || !hasPRRefs(refToHash) || !hasRefs(refToHash, refs) {
+		return nil, false
+	}
+	return refToHash, true
+}
+
+func hasRefs(refToHash RefToHash, refs []string) bool {
+	for _, ref := range refs {
+		if _, ok := refToHash[ref]; !ok {
+			return false
+		}
 	}
+	return true
+}
 
-	if len(prIDs) == 0 {
-		return "", errors.New("not found a pull request related to current branch")
+func hasPRRefs(refToHash RefToHash) bool {
+	for ref := range refToHash {
+		if isPRRef(ref) {
+			return true
+		}
 	}
+	return false
+}
 
-	sort.Sort(sort.Reverse(sort.StringSlice(prIDs)))
+// selectPullRequest picks the open one of candidates, sorted newest first.
+// choose is called when several are open. When none is open, the newest
+// closed or merged one is picked.
+func selectPullRequest(candidates []*PullRequestCandidate, choose func([]*PullRequestCandidate) (*PullRequestCandidate, error)) (*PullRequestCandidate, error) {
+	var open []*PullRequestCandidate
+	for _, c := range candidates {
+		if c.Open {
+			open = append(open, c)
+		}
+	}
+	switch len(open) {
+	case 0:
+		if len(candidates) == 0 {
+			return nil, errors.New("not found a pull request related to current branch")
+		}
+		return candidates[0], nil
+	case 1:
+		return open[0], nil
+	}
+	return choose(open)
+}
+
+// promptPullRequest asks the user to choose one of candidates on terminals.
+func promptPullRequest(in *os.File, out io.Writer) func([]*PullRequestCandidate) (*PullRequestCandidate, error) {
+	return func(candidates []*PullRequestCandidate) (*PullRequestCandidate, error) {
+		var ids []string
+		for _, c := range candidates {
+			ids = append(ids, "#"+strconv.Itoa(c.Number))
+		}
+		if !isTerminal(in) {
+			return nil, errors.Errorf("several pull requests are related to current branch: %s. specify <PR-ID>, or list them with --all",
+				strings.Join(ids, ", "))
+		}
+		fmt.Fprintln(out, "Several pull requests are related to current branch:")
+		for i, c := range candidates {
+			fmt.Fprintf(out, "  %d) %s %s\n", i+1, ids[i], c.Summary)
+		}
+		fmt.Fprint(out, "Choose one: ")
+		line, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		i, err := strconv.Atoi(strings.TrimSpace(line))
+		if err != nil || i < 1 || i > len(candidates) {
+			return nil, errors.Errorf("invalid choice: %s", strings.TrimSpace(line))
+		}
+		return candidates[i-1], nil
+	}
+}
 
-	return prIDs[0], nil
+// PrintPullRequestsOfBranch prints all pull requests related to current branch.
+func (b *BacklogRepository) PrintPullRequestsOfBranch(w io.Writer) error {
+	candidates, err := b.findPullRequests(b.repo.HeadName())
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, c := range candidates {
+		status := c.Status
+		if status == "" {
+			status = "-"
+		}
+		fmt.Fprintf(tw, "#%d\t%s\t%s\t%s\n", c.Number, status, c.Summary, b.urlBuilder().PullRequestURL(strconv.Itoa(c.Number)))
+	}
+	return tw.Flush()
 }
 
 func isPRRef(ref string) bool {
@@ -336,10 +740,7 @@ func (b *BacklogRepository) OpenAddPullRequest(base, topic string) error {
 	if topic == "" {
 		topic = b.repo.HeadShortName()
 	}
-	return b.openBrowser(NewBacklogURLBuilder(b.domain, b.spaceKey).
-		SetProjectKey(b.projectKey).
-		SetRepoName(b.repoName).
-		AddPullRequestURL(base, topic))
+	return b.openURL(b.urlBuilder().AddPullRequestURL(base, topic))
 }
 
 func (b *BacklogRepository) OpenIssue() error {
@@ -347,25 +748,57 @@ func (b *BacklogRepository) OpenIssue() error {
 	if key == "" {
 		return errors.New("could not find issue key in current branch name")
 	}
-	return b.openBrowser(NewBacklogURLBuilder(b.domain, b.spaceKey).
-		SetProjectKey(b.projectKey).
-		SetRepoName(b.repoName).
-		IssueURL(key))
+	return b.openURL(b.urlBuilder().IssueURL(key))
 }
 
+var issueKeyPattern = regexp.MustCompile("([A-Z0-9]+(?:_[A-Z0-9]+)*-[0-9]+)")
+
 func extractIssueKey(s string) string {
-	matches := regexp.MustCompile("([A-Z0-9]+(?:_[A-Z0-9]+)*-[0-9]+)").FindStringSubmatch(s)
+	matches := issueKeyPattern.FindStringSubmatch(s)
 	if len(matches) < 2 {
 		return ""
 	}
 	return matches[1]
 }
 
+// extractProjectIssueKey returns the first issue key of the project in s.
+// The words which look like issue keys of other projects, e.g. UTF-8 and
+// SHA-256, are skipped.
+func extractProjectIssueKey(s, projectKey string) string {
+	for _, key := range issueKeyPattern.FindAllString(s, -1) {
+		if strings.HasPrefix(key, projectKey+"-") {
+			return key
+		}
+	}
+	return ""
+}
+
+// extractProjectIssueKeys returns the distinct issue keys of the project in s.
+func extractProjectIssueKeys(s, projectKey string) []string {
+	var keys []string
+	for _, key := range extractIssueKeys(s) {
+		if strings.HasPrefix(key, projectKey+"-") {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// extractIssueKeys returns the distinct issue keys in s.
+func extractIssueKeys(s string) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, key := range issueKeyPattern.FindAllString(s, -1) {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
 func (b *BacklogRepository) OpenAddIssue() error {
-	return b.openBrowser(NewBacklogURLBuilder(b.domain, b.spaceKey).
-		SetProjectKey(b.projectKey).
-		SetRepoName(b.repoName).
-		AddIssueURL())
+	return b.openURL(b.urlBuilder().AddIssueURL())
 }
 
 type IssueStatus int
@@ -408,6 +841,11 @@ func (b *BacklogRepository) OpenIssueList(state string) error {
 	if err != nil {
 		return err
 	}
+	return b.openURL(b.urlBuilder().IssueListURL(s.statusIDs()))
+}
+
+// statusIDs returns the IDs of the statuses of issues in s, or nil for all.
+func (s IssueStatus) statusIDs() []int {
 	var statusIds []int
 	switch s {
 	case IssueStatusAll:
@@ -419,81 +857,5 @@ func (b *BacklogRepository) OpenIssueList(state string) error {
 	default:
 		statusIds = append(statusIds, s.Int())
 	}
-	return b.openBrowser(NewBacklogURLBuilder(b.domain, b.spaceKey).
-		SetProjectKey(b.projectKey).
-		SetRepoName(b.repoName).
-		IssueListURL(statusIds))
-}
-
-func (b *BacklogRepository) BlamePR(argv []string) error {
-	argv = append([]string{"blame", "--first-parent"}, argv...)
-	cmd := exec.CommandContext(context.Background(), "git", argv...)
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return err
-	}
-	err = cmd.Start()
-	if err != nil {
-		return err
-	}
-	defer func() {
-		_ = cmd.Wait()
-	}()
-	cached := make(map[string]string)
-	scanner := bufio.NewScanner(stdout)
-	for scanner.Scan() {
-		commitAndSrc := strings.SplitN(scanner.Text(), " ", 2)
-		commit, src := commitAndSrc[0], commitAndSrc[1]
-
-		if _, ok := cached[commit]; !ok {
-			pr, err := lookup(commit)
-			if err != nil {
-				return err
-			}
-			cached[commit] = pr
-		}
-
-		padding := len(commit)
-
-		if size := len(cached[commit]); padding < size {
-			padding = size
-		}
-
-		format := "%-" + strconv.Itoa(padding) + "s %s\n"
-		fmt.Printf(format, cached[commit], src)
-	}
-	return err
-}
-
-func lookup(commit string) (string, error) {
-	cmd := exec.CommandContext(context.Background(), "git", "show", "--oneline", commit)
-	out, err := cmd.Output()
-	if err != nil {
-		return commit, err
-	}
-	reg := regexp.MustCompile(`^[a-f0-9]+ Merge pull request #([0-9]+) \S+ into \S+`)
-	matches := reg.FindStringSubmatch(string(out))
-	if len(matches) < 1 {
-		return commit, nil
-	}
-	id, err := strconv.Atoi(matches[1])
-	if err != nil {
-		return commit, nil
-	}
-	return fmt.Sprintf("PR #%d", id), nil
-}
-
-func openBrowser(url string) error {
-	var err error
-	switch runtime.GOOS {
-	case "linux":
-		err = exec.Command("xdg-open", url).Start()
-	case "windows":
-		err = exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
-	case "darwin":
-		err = exec.Command("open", url).Start()
-	default:
-		err = fmt.Errorf("unsupported platform")
-	}
-	return err
+	return statusIds
 }
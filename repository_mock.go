@@ -1,13 +1,22 @@
 // Code generated by smock; DO NOT EDIT.
 package main
 
+import "os/exec"
+
 type RepositoryMock struct {
 	HeadNameFunc           func() string
 	HeadShortNameFunc      func() string
-	RemoteEndpointHostFunc func() string
-	RemoteEndpointPathFunc func() string
+	RemoteNameFunc         func() string
+	RemoteURLFunc          func() string
 	RootDirectoryFunc 	   func() string
+	HeadHashFunc           func() string
 	LsRemoteFunc           func() (RefToHash, error)
+	DefaultBranchFunc      func() (string, error)
+	CommitsSinceFunc       func(base string) ([]*Commit, error)
+	PushFunc               func(branch string) error
+	FetchFunc              func(refs ...string) error
+	LocalRemoteRefsFunc    func() (RefToHash, error)
+	GitFunc                func(args ...string) *exec.Cmd
 }
 
 func (m *RepositoryMock) HeadName() string {
@@ -24,18 +33,18 @@ func (m *RepositoryMock) HeadShortName() string {
 	return m.HeadShortNameFunc()
 }
 
-func (m *RepositoryMock) RemoteEndpointHost() string {
-	if m.RemoteEndpointHostFunc == nil {
+func (m *RepositoryMock) RemoteName() string {
+	if m.RemoteNameFunc == nil {
 		panic("This method is not defined.")
 	}
-	return m.RemoteEndpointHostFunc()
+	return m.RemoteNameFunc()
 }
 
-func (m *RepositoryMock) RemoteEndpointPath() string {
-	if m.RemoteEndpointPathFunc == nil {
+func (m *RepositoryMock) RemoteURL() string {
+	if m.RemoteURLFunc == nil {
 		panic("This method is not defined.")
 	}
-	return m.RemoteEndpointPathFunc()
+	return m.RemoteURLFunc()
 }
 
 func (m *RepositoryMock) RootDirectory() string {
@@ -51,3 +60,52 @@ func (m *RepositoryMock) LsRemote() (RefToHash, error) {
 	}
 	return m.LsRemoteFunc()
 }
+
+func (m *RepositoryMock) HeadHash() string {
+	if m.HeadHashFunc == nil {
+		panic("This method is not defined.")
+	}
+	return m.HeadHashFunc()
+}
+
+func (m *RepositoryMock) DefaultBranch() (string, error) {
+	if m.DefaultBranchFunc == nil {
+		panic("This method is not defined.")
+	}
+	return m.DefaultBranchFunc()
+}
+
+func (m *RepositoryMock) CommitsSince(base string) ([]*Commit, error) {
+	if m.CommitsSinceFunc == nil {
+		panic("This method is not defined.")
+	}
+	return m.CommitsSinceFunc(base)
+}
+
+func (m *RepositoryMock) Push(branch string) error {
+	if m.PushFunc == nil {
+		panic("This method is not defined.")
+	}
+	return m.PushFunc(branch)
+}
+
+func (m *RepositoryMock) Fetch(refs ...string) error {
+	if m.FetchFunc == nil {
+		panic("This method is not defined.")
+	}
+	return m.FetchFunc(refs...)
+}
+
+func (m *RepositoryMock) LocalRemoteRefs() (RefToHash, error) {
+	if m.LocalRemoteRefsFunc == nil {
+		panic("This method is not defined.")
+	}
+	return m.LocalRemoteRefsFunc()
+}
+
+func (m *RepositoryMock) Git(args ...string) *exec.Cmd {
+	if m.GitFunc == nil {
+		panic("This method is not defined.")
+	}
+	return m.GitFunc(args...)
+}
@@ -1,13 +1,79 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
+	"strconv"
 	"testing"
 
 	"github.com/pkg/errors"
+	"github.com/vvatanabe/gitb/internal/backlog"
 )
 
+// newGitRepo makes an empty repository on master and changes into it. It
+// returns the functions which run git in it and commit a file.
+func newGitRepo(t *testing.T) (git func(args ...string), commit func(name, content, message string)) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(wd)
+	})
+	setenv(t, "HOME", dir)
+	setenv(t, "GIT_CONFIG_NOSYSTEM", "1")
+	setenv(t, "GIT_AUTHOR_NAME", "Alice")
+	setenv(t, "GIT_AUTHOR_EMAIL", "alice@example.com")
+	setenv(t, "GIT_COMMITTER_NAME", "Alice")
+	setenv(t, "GIT_COMMITTER_EMAIL", "alice@example.com")
+	git = func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	commit = func(name, content, message string) {
+		t.Helper()
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		git("add", name)
+		git("commit", "-q", "-m", message)
+	}
+	git("init", "-q")
+	git("symbolic-ref", "HEAD", "refs/heads/master")
+	return git, commit
+}
+
+// workingDirGit is a Git of RepositoryMock which runs git in the working
+// directory, e.g. of newGitRepo.
+func workingDirGit(args ...string) *exec.Cmd {
+	return exec.Command("git", args...)
+}
+
+// fakeGitCommand returns a Git of RepositoryMock which records the arguments
+// in calls, and whose commands fail when fail reports so.
+func fakeGitCommand(calls *[][]string, fail func(args []string) bool) func(args ...string) *exec.Cmd {
+	return func(args ...string) *exec.Cmd {
+		*calls = append(*calls, args)
+		if fail != nil && fail(args) {
+			return exec.Command("false")
+		}
+		return exec.Command("true")
+	}
+}
+
 func Test_toRefToHash(t *testing.T) {
 	out := []byte(`e73e35d0a86218a9624167110ff8e7fe42596234	HEAD
 e73e35d0a86218a9624167110ff8e7fe42596234	refs/heads/master
@@ -33,6 +99,16 @@ e73e35d0a86218a9624167110ff8e7fe42596234	refs/heads/master
 				"refs/tags/0.0.0":    "2674ad54e116b4a05d933aa75c7af0657afd0079",
 			},
 		},
+		{
+			name: "empty",
+			args: args{[]byte("")},
+			want: RefToHash{},
+		},
+		{
+			name: "malformed",
+			args: args{[]byte("warning: redirecting\ne73e35d0a86218a9624167110ff8e7fe42596234\trefs/heads/master\n\n")},
+			want: RefToHash{"refs/heads/master": "e73e35d0a86218a9624167110ff8e7fe42596234"},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -106,6 +182,7 @@ func Test_extractProjectKeyAndRepoName(t *testing.T) {
 		args           args
 		wantProjectKey string
 		wantRepoName   string
+		wantErr        bool
 	}{
 		{
 			args:           args{"/FOO/bar.git"},
@@ -117,10 +194,26 @@ func Test_extractProjectKeyAndRepoName(t *testing.T) {
 			wantProjectKey: "FOO",
 			wantRepoName:   "bar",
 		},
+		{
+			args:    args{"/bar.git"},
+			wantErr: true,
+		},
+		{
+			args:    args{"/foo/bar/baz.git"},
+			wantErr: true,
+		},
+		{
+			args:    args{""},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotProjectKey, gotRepoName := extractProjectKeyAndRepoName(tt.args.path)
+			gotProjectKey, gotRepoName, err := extractProjectKeyAndRepoName(tt.args.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("extractProjectKeyAndRepoName() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
 			if gotProjectKey != tt.wantProjectKey {
 				t.Errorf("extractProjectKeyAndRepoName() gotProjectKey = %v, want %v", gotProjectKey, tt.wantProjectKey)
 			}
@@ -165,12 +258,12 @@ func TestBacklogRepository_OpenRepositoryList(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			b := &BacklogRepository{
-				openBrowser: tt.fields.openBrowser,
-				repo:        tt.fields.repo,
-				domain:      tt.fields.domain,
-				spaceKey:    tt.fields.spaceKey,
-				projectKey:  tt.fields.projectKey,
-				repoName:    tt.fields.repoName,
+				openURL:    tt.fields.openBrowser,
+				repo:       tt.fields.repo,
+				domain:     tt.fields.domain,
+				spaceKey:   tt.fields.spaceKey,
+				projectKey: tt.fields.projectKey,
+				repoName:   tt.fields.repoName,
 			}
 			if err := b.OpenRepositoryList(); (err != nil) != tt.wantErr {
 				t.Errorf("BacklogRepository.OpenRepositoryList() error = %v, wantErr %v", err, tt.wantErr)
@@ -222,12 +315,12 @@ func TestBacklogRepository_OpenTree(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			b := &BacklogRepository{
-				openBrowser: tt.fields.openBrowser,
-				repo:        tt.fields.repo,
-				domain:      tt.fields.domain,
-				spaceKey:    tt.fields.spaceKey,
-				projectKey:  tt.fields.projectKey,
-				repoName:    tt.fields.repoName,
+				openURL:    tt.fields.openBrowser,
+				repo:       tt.fields.repo,
+				domain:     tt.fields.domain,
+				spaceKey:   tt.fields.spaceKey,
+				projectKey: tt.fields.projectKey,
+				repoName:   tt.fields.repoName,
 			}
 			if err := b.OpenTree(tt.args.refOrHash); (err != nil) != tt.wantErr {
 				t.Errorf("BacklogRepository.OpenTree() error = %v, wantErr %v", err, tt.wantErr)
@@ -280,12 +373,12 @@ func TestBacklogRepository_OpenObject(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			b := &BacklogRepository{
-				openBrowser: tt.fields.openBrowser,
-				repo:        tt.fields.repo,
-				domain:      tt.fields.domain,
-				spaceKey:    tt.fields.spaceKey,
-				projectKey:  tt.fields.projectKey,
-				repoName:    tt.fields.repoName,
+				openURL:    tt.fields.openBrowser,
+				repo:       tt.fields.repo,
+				domain:     tt.fields.domain,
+				spaceKey:   tt.fields.spaceKey,
+				projectKey: tt.fields.projectKey,
+				repoName:   tt.fields.repoName,
 			}
 			if err := b.OpenObject("/path/to/repo/path/to/dir", true, ""); (err != nil) != tt.wantErr {
 				t.Errorf("BacklogRepository.OpenObject() error = %v, wantErr %v", err, tt.wantErr)
@@ -355,12 +448,12 @@ func TestBacklogRepository_OpenHistory(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			b := &BacklogRepository{
-				openBrowser: tt.fields.openBrowser,
-				repo:        tt.fields.repo,
-				domain:      tt.fields.domain,
-				spaceKey:    tt.fields.spaceKey,
-				projectKey:  tt.fields.projectKey,
-				repoName:    tt.fields.repoName,
+				openURL:    tt.fields.openBrowser,
+				repo:       tt.fields.repo,
+				domain:     tt.fields.domain,
+				spaceKey:   tt.fields.spaceKey,
+				projectKey: tt.fields.projectKey,
+				repoName:   tt.fields.repoName,
 			}
 			if err := b.OpenHistory(tt.args.refOrHash); (err != nil) != tt.wantErr {
 				t.Errorf("BacklogRepository.OpenHistory() error = %v, wantErr %v", err, tt.wantErr)
@@ -412,12 +505,12 @@ func TestBacklogRepository_OpenNetwork(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			b := &BacklogRepository{
-				openBrowser: tt.fields.openBrowser,
-				repo:        tt.fields.repo,
-				domain:      tt.fields.domain,
-				spaceKey:    tt.fields.spaceKey,
-				projectKey:  tt.fields.projectKey,
-				repoName:    tt.fields.repoName,
+				openURL:    tt.fields.openBrowser,
+				repo:       tt.fields.repo,
+				domain:     tt.fields.domain,
+				spaceKey:   tt.fields.spaceKey,
+				projectKey: tt.fields.projectKey,
+				repoName:   tt.fields.repoName,
 			}
 			if err := b.OpenNetwork(tt.args.refOrHash); (err != nil) != tt.wantErr {
 				t.Errorf("BacklogRepository.OpenNetwork() error = %v, wantErr %v", err, tt.wantErr)
@@ -461,12 +554,12 @@ func TestBacklogRepository_OpenBranchList(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			b := &BacklogRepository{
-				openBrowser: tt.fields.openBrowser,
-				repo:        tt.fields.repo,
-				domain:      tt.fields.domain,
-				spaceKey:    tt.fields.spaceKey,
-				projectKey:  tt.fields.projectKey,
-				repoName:    tt.fields.repoName,
+				openURL:    tt.fields.openBrowser,
+				repo:       tt.fields.repo,
+				domain:     tt.fields.domain,
+				spaceKey:   tt.fields.spaceKey,
+				projectKey: tt.fields.projectKey,
+				repoName:   tt.fields.repoName,
 			}
 			if err := b.OpenBranchList(); (err != nil) != tt.wantErr {
 				t.Errorf("BacklogRepository.OpenBranchList() error = %v, wantErr %v", err, tt.wantErr)
@@ -510,12 +603,12 @@ func TestBacklogRepository_OpenTagList(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			b := &BacklogRepository{
-				openBrowser: tt.fields.openBrowser,
-				repo:        tt.fields.repo,
-				domain:      tt.fields.domain,
-				spaceKey:    tt.fields.spaceKey,
-				projectKey:  tt.fields.projectKey,
-				repoName:    tt.fields.repoName,
+				openURL:    tt.fields.openBrowser,
+				repo:       tt.fields.repo,
+				domain:     tt.fields.domain,
+				spaceKey:   tt.fields.spaceKey,
+				projectKey: tt.fields.projectKey,
+				repoName:   tt.fields.repoName,
 			}
 			if err := b.OpenTagList(); (err != nil) != tt.wantErr {
 				t.Errorf("BacklogRepository.OpenTagList() error = %v, wantErr %v", err, tt.wantErr)
@@ -564,12 +657,12 @@ func TestBacklogRepository_OpenPullRequestList(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			b := &BacklogRepository{
-				openBrowser: tt.fields.openBrowser,
-				repo:        tt.fields.repo,
-				domain:      tt.fields.domain,
-				spaceKey:    tt.fields.spaceKey,
-				projectKey:  tt.fields.projectKey,
-				repoName:    tt.fields.repoName,
+				openURL:    tt.fields.openBrowser,
+				repo:       tt.fields.repo,
+				domain:     tt.fields.domain,
+				spaceKey:   tt.fields.spaceKey,
+				projectKey: tt.fields.projectKey,
+				repoName:   tt.fields.repoName,
 			}
 			if err := b.OpenPullRequestList(tt.args.status); (err != nil) != tt.wantErr {
 				t.Errorf("BacklogRepository.OpenPullRequestList() error = %v, wantErr %v", err, tt.wantErr)
@@ -659,6 +752,8 @@ func TestPRStatusFromString(t *testing.T) {
 }
 
 func TestBacklogRepository_OpenPullRequest(t *testing.T) {
+	setenv(t, apiKeyEnv, "")
+	setenv(t, "XDG_CONFIG_HOME", t.TempDir())
 	type fields struct {
 		openBrowser func(url string) error
 		repo        Repository
@@ -685,6 +780,9 @@ func TestBacklogRepository_OpenPullRequest(t *testing.T) {
 					HeadNameFunc: func() string {
 						return "refs/heads/patch-1"
 					},
+					LocalRemoteRefsFunc: func() (RefToHash, error) {
+						return RefToHash{}, nil
+					},
 					LsRemoteFunc: func() (RefToHash, error) {
 						refToHash := make(RefToHash)
 						refToHash["HEAD"] = "e73e35d0a86218a9624167110ff8e7fe42596234"
@@ -715,6 +813,9 @@ func TestBacklogRepository_OpenPullRequest(t *testing.T) {
 					HeadNameFunc: func() string {
 						return "refs/tags/0.0.0"
 					},
+					LocalRemoteRefsFunc: func() (RefToHash, error) {
+						return RefToHash{}, nil
+					},
 					LsRemoteFunc: func() (RefToHash, error) {
 						refToHash := make(RefToHash)
 						refToHash["HEAD"] = "e73e35d0a86218a9624167110ff8e7fe42596234"
@@ -745,6 +846,9 @@ func TestBacklogRepository_OpenPullRequest(t *testing.T) {
 					HeadNameFunc: func() string {
 						return "refs/heads/patch-2"
 					},
+					LocalRemoteRefsFunc: func() (RefToHash, error) {
+						return RefToHash{}, nil
+					},
 					LsRemoteFunc: func() (RefToHash, error) {
 						refToHash := make(RefToHash)
 						refToHash["HEAD"] = "e73e35d0a86218a9624167110ff8e7fe42596234"
@@ -766,12 +870,12 @@ func TestBacklogRepository_OpenPullRequest(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			b := &BacklogRepository{
-				openBrowser: tt.fields.openBrowser,
-				repo:        tt.fields.repo,
-				domain:      tt.fields.domain,
-				spaceKey:    tt.fields.spaceKey,
-				projectKey:  tt.fields.projectKey,
-				repoName:    tt.fields.repoName,
+				openURL:    tt.fields.openBrowser,
+				repo:       tt.fields.repo,
+				domain:     tt.fields.domain,
+				spaceKey:   tt.fields.spaceKey,
+				projectKey: tt.fields.projectKey,
+				repoName:   tt.fields.repoName,
 			}
 			if err := b.OpenPullRequest(); (err != nil) != tt.wantErr {
 				t.Errorf("BacklogRepository.OpenPullRequest() error = %v, wantErr %v", err, tt.wantErr)
@@ -914,12 +1018,12 @@ func TestBacklogRepository_OpenAddPullRequest(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			b := &BacklogRepository{
-				openBrowser: tt.fields.openBrowser,
-				repo:        tt.fields.repo,
-				domain:      tt.fields.domain,
-				spaceKey:    tt.fields.spaceKey,
-				projectKey:  tt.fields.projectKey,
-				repoName:    tt.fields.repoName,
+				openURL:    tt.fields.openBrowser,
+				repo:       tt.fields.repo,
+				domain:     tt.fields.domain,
+				spaceKey:   tt.fields.spaceKey,
+				projectKey: tt.fields.projectKey,
+				repoName:   tt.fields.repoName,
 			}
 			if err := b.OpenAddPullRequest(tt.args.base, tt.args.topic); (err != nil) != tt.wantErr {
 				t.Errorf("BacklogRepository.OpenAddPullRequest() error = %v, wantErr %v", err, tt.wantErr)
@@ -984,12 +1088,12 @@ func TestBacklogRepository_OpenIssue(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			b := &BacklogRepository{
-				openBrowser: tt.fields.openBrowser,
-				repo:        tt.fields.repo,
-				domain:      tt.fields.domain,
-				spaceKey:    tt.fields.spaceKey,
-				projectKey:  tt.fields.projectKey,
-				repoName:    tt.fields.repoName,
+				openURL:    tt.fields.openBrowser,
+				repo:       tt.fields.repo,
+				domain:     tt.fields.domain,
+				spaceKey:   tt.fields.spaceKey,
+				projectKey: tt.fields.projectKey,
+				repoName:   tt.fields.repoName,
 			}
 			if err := b.OpenIssue(); (err != nil) != tt.wantErr {
 				t.Errorf("BacklogRepository.OpenIssue() error = %v, wantErr %v", err, tt.wantErr)
@@ -1037,6 +1141,45 @@ func Test_extractIssueKey(t *testing.T) {
 	}
 }
 
+func Test_extractIssueKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want []string
+	}{
+		{name: "none", s: "Fix a typo", want: nil},
+		{name: "merge", s: "Merge pull request #7 feature/BAR-12 into master", want: []string{"BAR-12"}},
+		{name: "several", s: "BAR-1 and FOO_BAR-2, again BAR-1", want: []string{"BAR-1", "FOO_BAR-2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractIssueKeys(tt.s); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extractIssueKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_extractProjectIssueKey(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{name: "none", s: "Fix a typo", want: ""},
+		{name: "project", s: "Fix BAR-12", want: "BAR-12"},
+		{name: "other words", s: "Use UTF-8 and SHA-256 for BAR-12", want: "BAR-12"},
+		{name: "other projects", s: "FOO-1, FOO_BAR-2 and XBAR-3", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractProjectIssueKey(tt.s, "BAR"); got != tt.want {
+				t.Errorf("extractProjectIssueKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestBacklogRepository_OpenAddIssue(t *testing.T) {
 	type fields struct {
 		openBrowser func(url string) error
@@ -1072,12 +1215,12 @@ func TestBacklogRepository_OpenAddIssue(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			b := &BacklogRepository{
-				openBrowser: tt.fields.openBrowser,
-				repo:        tt.fields.repo,
-				domain:      tt.fields.domain,
-				spaceKey:    tt.fields.spaceKey,
-				projectKey:  tt.fields.projectKey,
-				repoName:    tt.fields.repoName,
+				openURL:    tt.fields.openBrowser,
+				repo:       tt.fields.repo,
+				domain:     tt.fields.domain,
+				spaceKey:   tt.fields.spaceKey,
+				projectKey: tt.fields.projectKey,
+				repoName:   tt.fields.repoName,
 			}
 			if err := b.OpenAddIssue(); (err != nil) != tt.wantErr {
 				t.Errorf("BacklogRepository.OpenAddIssue() error = %v, wantErr %v", err, tt.wantErr)
@@ -1275,12 +1418,12 @@ func TestBacklogRepository_OpenIssueList(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			b := &BacklogRepository{
-				openBrowser: tt.fields.openBrowser,
-				repo:        tt.fields.repo,
-				domain:      tt.fields.domain,
-				spaceKey:    tt.fields.spaceKey,
-				projectKey:  tt.fields.projectKey,
-				repoName:    tt.fields.repoName,
+				openURL:    tt.fields.openBrowser,
+				repo:       tt.fields.repo,
+				domain:     tt.fields.domain,
+				spaceKey:   tt.fields.spaceKey,
+				projectKey: tt.fields.projectKey,
+				repoName:   tt.fields.repoName,
 			}
 			if err := b.OpenIssueList(tt.args.state); (err != nil) != tt.wantErr {
 				t.Errorf("BacklogRepository.OpenIssueList() error = %v, wantErr %v", err, tt.wantErr)
@@ -1325,12 +1468,12 @@ func TestBacklogRepository_OpenCommit(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			b := &BacklogRepository{
-				openBrowser: tt.fields.openBrowser,
-				repo:        tt.fields.repo,
-				domain:      tt.fields.domain,
-				spaceKey:    tt.fields.spaceKey,
-				projectKey:  tt.fields.projectKey,
-				repoName:    tt.fields.repoName,
+				openURL:    tt.fields.openBrowser,
+				repo:       tt.fields.repo,
+				domain:     tt.fields.domain,
+				spaceKey:   tt.fields.spaceKey,
+				projectKey: tt.fields.projectKey,
+				repoName:   tt.fields.repoName,
 			}
 			if err := b.OpenCommit(tt.fields.hash); (err != nil) != tt.wantErr {
 				t.Errorf("BacklogRepository.OpenCommit() error = %v, wantErr %v", err, tt.wantErr)
@@ -1338,3 +1481,490 @@ func TestBacklogRepository_OpenCommit(t *testing.T) {
 		})
 	}
 }
+
+func Test_isBacklogHost(t *testing.T) {
+	type args struct {
+		host string
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{
+			args: args{"foo.backlog.com"},
+			want: true,
+		},
+		{
+			args: args{"foo.git.backlog.jp"},
+			want: true,
+		},
+		{
+			args: args{"foo.backlogtool.com"},
+			want: true,
+		},
+		{
+			args: args{"github.com"},
+			want: false,
+		},
+		{
+			args: args{"backlog.com.example.com"},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isBacklogHost(tt.args.host); got != tt.want {
+				t.Errorf("isBacklogHost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_chooseBacklogRemote(t *testing.T) {
+	type args struct {
+		nameToURL map[string]string
+		hosts     Hosts
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{
+			args: args{map[string]string{
+				"origin":  "git@github.com:foo/bar.git",
+				"backlog": "foo@foo.git.backlog.com:/BAR/baz.git",
+			}, nil},
+			want: "backlog",
+		},
+		{
+			args: args{map[string]string{
+				"origin":   "https://foo.backlog.com/git/BAR/baz.git",
+				"upstream": "https://foo.backlog.jp/git/BAR/baz.git",
+			}, nil},
+			want: "origin",
+		},
+		{
+			args: args{map[string]string{
+				"backlog":  "https://foo.backlog.com/git/BAR/baz.git",
+				"upstream": "https://foo.backlog.jp/git/BAR/baz.git",
+			}, nil},
+			wantErr: true,
+		},
+		{
+			args: args{map[string]string{
+				"origin": "git@github.com:foo/bar.git",
+			}, nil},
+			wantErr: true,
+		},
+		{
+			args: args{map[string]string{
+				"origin":  "git@github.com:foo/bar.git",
+				"backlog": "ssh://git@backlog.corp.example.com:2222/BAR/baz.git",
+			}, Hosts{
+				"backlog.corp.example.com": HostConfig{BaseURL: "https://backlog.corp.example.com"},
+			}},
+			want: "backlog",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := chooseBacklogRemote(tt.args.nameToURL, tt.args.hosts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("chooseBacklogRemote() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("chooseBacklogRemote() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewBacklogRepository(t *testing.T) {
+	type args struct {
+		repo Repository
+		cfg  *Config
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{
+			args: args{
+				repo: &RepositoryMock{
+					RemoteURLFunc: func() string {
+						return "foo@foo.git.backlog.jp:/BAR/baz.git"
+					},
+				},
+			},
+			want: "https://foo.backlog.jp/git/BAR/baz",
+		},
+		{
+			args: args{
+				repo: &RepositoryMock{
+					RemoteURLFunc: func() string {
+						return "https://foo.backlogtool.com/git/BAR/baz.git"
+					},
+				},
+			},
+			want: "https://foo.backlogtool.com/git/BAR/baz",
+		},
+		{
+			args: args{
+				repo: &RepositoryMock{
+					RemoteURLFunc: func() string {
+						return "ssh://git@git.corp.example.com:2222/backlog/git/BAR/baz.git"
+					},
+				},
+				cfg: &Config{layers: []*configLayer{{
+					values: map[string]string{
+						"git.corp.example.com.baseurl":    "https://backlog.corp.example.com:8443/backlog",
+						"git.corp.example.com.space":      "corp",
+						"git.corp.example.com.pathprefix": "/backlog",
+					},
+				}}},
+			},
+			want: "https://backlog.corp.example.com:8443/backlog/git/BAR/baz",
+		},
+		{
+			args: args{
+				repo: &RepositoryMock{
+					RemoteURLFunc: func() string {
+						return "https://foo.backlog.com/git/BAR/baz.git"
+					},
+				},
+				cfg: &Config{layers: []*configLayer{{
+					values: map[string]string{"space": "qux"},
+				}}},
+			},
+			want: "https://qux.backlog.com/git/BAR/baz",
+		},
+		{
+			args: args{
+				repo: &RepositoryMock{
+					RemoteURLFunc: func() string {
+						return "git@github.com:foo/bar.git"
+					},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := NewBacklogRepository(tt.args.repo, tt.args.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewBacklogRepository() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if got := b.urlBuilder().GitRepoBaseURL(); got != tt.want {
+				t.Errorf("NewBacklogRepository().urlBuilder().GitRepoBaseURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBacklogRepository_NewAPIClient(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		want    string
+	}{
+		{
+			want: "https://foo.backlog.com",
+		},
+		{
+			baseURL: "https://backlog.corp.example.com/backlog",
+			want:    "https://backlog.corp.example.com/backlog",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &BacklogRepository{
+				baseURL:    tt.baseURL,
+				domain:     "backlog.com",
+				spaceKey:   "foo",
+				projectKey: "BAR",
+				repoName:   "baz",
+			}
+			if got := b.NewAPIClient("secret").BaseURL(); got != tt.want {
+				t.Errorf("BacklogRepository.NewAPIClient().BaseURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseCommits(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want []*Commit
+	}{
+		{
+			name: "empty",
+			s:    "",
+			want: nil,
+		},
+		{
+			name: "commits",
+			s:    "1\x00Add foo\x00Because of bar.\n\x1e\n2\x00Fix foo\x00\x1e\n",
+			want: []*Commit{
+				{Hash: "1", Subject: "Add foo", Body: "Because of bar."},
+				{Hash: "2", Subject: "Fix foo"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseCommits(tt.s); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCommits() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBacklogRepository_findPullRequests(t *testing.T) {
+	setenv(t, apiKeyEnv, "")
+	setenv(t, "XDG_CONFIG_HOME", t.TempDir())
+	remote := RefToHash{
+		"refs/heads/master":  "e73e35d0a86218a9624167110ff8e7fe42596234",
+		"refs/heads/patch-1": "2b2b5f9e8508a976096a50bd37c81c17ccdf7fb4",
+		"refs/pull/9/head":   "2b2b5f9e8508a976096a50bd37c81c17ccdf7fb4",
+		"refs/pull/12/head":  "2b2b5f9e8508a976096a50bd37c81c17ccdf7fb4",
+		"refs/pull/13/head":  "e73e35d0a86218a9624167110ff8e7fe42596234",
+	}
+	fetched := RefToHash{
+		"refs/heads/master":  "e73e35d0a86218a9624167110ff8e7fe42596234",
+		"refs/heads/patch-1": "2b2b5f9e8508a976096a50bd37c81c17ccdf7fb4",
+		"refs/pull/9/head":   "2b2b5f9e8508a976096a50bd37c81c17ccdf7fb4",
+	}
+	prs := []*backlog.PullRequest{
+		{Number: 14, Branch: "patch-2", Status: &backlog.Status{ID: 1, Name: "Open"}},
+		{Number: 12, Branch: "patch-1", Status: &backlog.Status{ID: 1, Name: "Open"}},
+		{Number: 9, Branch: "patch-1", Status: &backlog.Status{ID: 3, Name: "Merged"}},
+		{Number: 7, Branch: "patch-0", Status: &backlog.Status{ID: 2, Name: "Closed"}},
+	}
+	// queries are the statusId[] of the requests of the pull requests.
+	var queries [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		queries = append(queries, q["statusId[]"])
+		if q.Get("count") != "100" || q.Get("offset") != "0" && q.Get("offset") != "100" {
+			t.Errorf("query = %v", q)
+		}
+		var page []*backlog.PullRequest
+		for _, pr := range prs {
+			for _, id := range q["statusId[]"] {
+				if id == strconv.Itoa(pr.Status.ID) {
+					page = append(page, pr)
+				}
+			}
+		}
+		_ = json.NewEncoder(w).Encode(page)
+	}))
+	t.Cleanup(server.Close)
+	api := backlog.NewClient(server.URL).SetAPIKey("key")
+	tests := []struct {
+		name    string
+		api     *backlog.Client
+		local   RefToHash
+		online  bool
+		ref     string
+		want    []*PullRequestCandidate
+		wantErr bool
+		// wantQueries are the statusId[] of the requests to the API.
+		wantQueries [][]string
+	}{
+		{
+			name: "remote",
+			ref:  "refs/heads/patch-1",
+			want: []*PullRequestCandidate{
+				{Number: 12, Open: true},
+				{Number: 9, Open: true},
+			},
+		},
+		{
+			name:  "local",
+			local: fetched,
+			ref:   "refs/heads/patch-1",
+			want: []*PullRequestCandidate{
+				{Number: 9, Open: true},
+			},
+		},
+		{
+			name:  "opened after fetch",
+			local: fetched,
+			ref:   "refs/heads/master",
+			want: []*PullRequestCandidate{
+				{Number: 13, Open: true},
+			},
+		},
+		{
+			name:   "online",
+			local:  fetched,
+			online: true,
+			ref:    "refs/heads/patch-1",
+			want: []*PullRequestCandidate{
+				{Number: 12, Open: true},
+				{Number: 9, Open: true},
+			},
+		},
+		{
+			name:  "branch not fetched",
+			local: RefToHash{"refs/pull/9/head": "2b2b5f9e8508a976096a50bd37c81c17ccdf7fb4"},
+			ref:   "refs/heads/master",
+			want: []*PullRequestCandidate{
+				{Number: 13, Open: true},
+			},
+		},
+		{
+			name:        "api",
+			api:         api,
+			ref:         "refs/heads/patch-1",
+			wantQueries: [][]string{{"1"}},
+			want: []*PullRequestCandidate{
+				{Number: 12, Status: "Open", Open: true},
+			},
+		},
+		{
+			name:        "api falls back to closed and merged",
+			api:         api,
+			ref:         "refs/heads/patch-0",
+			wantQueries: [][]string{{"1"}, {"2", "3"}},
+			want: []*PullRequestCandidate{
+				{Number: 7, Status: "Closed"},
+			},
+		},
+		{
+			name:        "api falls back to remote",
+			api:         api,
+			ref:         "refs/heads/master",
+			wantQueries: [][]string{{"1"}, {"2", "3"}},
+			want: []*PullRequestCandidate{
+				{Number: 13, Open: true},
+			},
+		},
+		{
+			name:    "not on a branch",
+			ref:     "refs/tags/0.0.0",
+			wantErr: true,
+		},
+		{
+			name:    "not in remote",
+			ref:     "refs/heads/patch-3",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local := tt.local
+			b := &BacklogRepository{
+				repo: &RepositoryMock{
+					LocalRemoteRefsFunc: func() (RefToHash, error) {
+						return local, nil
+					},
+					LsRemoteFunc: func() (RefToHash, error) {
+						return remote, nil
+					},
+				},
+				api:        tt.api,
+				online:     tt.online,
+				domain:     "backlog.com",
+				spaceKey:   "foo",
+				projectKey: "BAR",
+				repoName:   "baz",
+			}
+			queries = nil
+			got, err := b.findPullRequests(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BacklogRepository.findPullRequests() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BacklogRepository.findPullRequests() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(queries, tt.wantQueries) {
+				t.Errorf("BacklogRepository.findPullRequests() queries = %v, want %v", queries, tt.wantQueries)
+			}
+		})
+	}
+}
+
+func Test_remoteRefName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "master", want: "refs/heads/master"},
+		{name: "feature/foo", want: "refs/heads/feature/foo"},
+		{name: "pr/12", want: "refs/pull/12/head"},
+		{name: "pr/foo", want: "refs/heads/pr/foo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := remoteRefName(tt.name); got != tt.want {
+				t.Errorf("remoteRefName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_selectPullRequest(t *testing.T) {
+	chooseLast := func(c []*PullRequestCandidate) (*PullRequestCandidate, error) {
+		return c[len(c)-1], nil
+	}
+	tests := []struct {
+		name       string
+		candidates []*PullRequestCandidate
+		want       int
+		wantErr    bool
+	}{
+		{
+			name: "single open",
+			candidates: []*PullRequestCandidate{
+				{Number: 12, Status: "Merged"},
+				{Number: 9, Open: true},
+			},
+			want: 9,
+		},
+		{
+			name: "newest closed",
+			candidates: []*PullRequestCandidate{
+				{Number: 12, Status: "Merged"},
+				{Number: 9, Status: "Closed"},
+			},
+			want: 12,
+		},
+		{
+			name: "choose from open",
+			candidates: []*PullRequestCandidate{
+				{Number: 13, Open: true},
+				{Number: 12, Status: "Merged"},
+				{Number: 9, Open: true},
+			},
+			want: 9,
+		},
+		{
+			name:    "none",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectPullRequest(tt.candidates, chooseLast)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectPullRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.Number != tt.want {
+				t.Errorf("selectPullRequest() = %v, want %v", got.Number, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// URLSink receives the URLs built by BacklogRepository.
+// e.g. launching a browser, printing to stdout, or copying to the clipboard.
+type URLSink func(url string) error
+
+// defaultURLSink launches the browser. When no browser can be shown, e.g. in
+// SSH sessions or containers, URLs are printed to stdout instead unless a
+// browser is configured explicitly.
+func defaultURLSink(cfg *Config) URLSink {
+	if cfg.Get("browser") == "" && os.Getenv("BROWSER") == "" && !hasDisplay(runtime.GOOS, os.Getenv) {
+		return PrintSink(os.Stdout)
+	}
+	return defaultBrowserSink(cfg)
+}
+
+// PrintSink writes URLs to w line by line.
+func PrintSink(w io.Writer) URLSink {
+	return func(url string) error {
+		_, err := fmt.Fprintln(w, url)
+		return err
+	}
+}
+
+// CopySink copies URLs to the clipboard with a clipboard command, or with
+// the OSC 52 escape sequence written to tty when no command is available.
+func CopySink(tty io.Writer) URLSink {
+	return func(url string) error {
+		name, args, ok := clipboardCommand(runtime.GOOS, os.Getenv, exec.LookPath)
+		if ok {
+			cmd := exec.Command(name, args...)
+			cmd.Stdin = strings.NewReader(url)
+			cmd.Stderr = os.Stderr
+			if err := cmd.Run(); err != nil {
+				return err
+			}
+		} else if _, err := io.WriteString(tty, osc52(url, os.Getenv("TMUX") != "")); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(os.Stderr, "Copied "+url)
+		return err
+	}
+}
+
+// clipboardCommand returns the command to copy its stdin to the clipboard.
+// It returns false in SSH sessions, where only OSC 52 reaches the local clipboard.
+func clipboardCommand(goos string, getenv func(string) string, lookPath func(string) (string, error)) (name string, args []string, ok bool) {
+	if isSSHSession(getenv) {
+		return "", nil, false
+	}
+	var candidates [][]string
+	switch {
+	case goos == "darwin":
+		candidates = append(candidates, []string{"pbcopy"})
+	case goos == "windows" || detectWSL():
+		candidates = append(candidates, []string{"clip.exe"})
+	}
+	if getenv("WAYLAND_DISPLAY") != "" {
+		candidates = append(candidates, []string{"wl-copy"})
+	}
+	if getenv("DISPLAY") != "" {
+		candidates = append(candidates,
+			[]string{"xclip", "-selection", "clipboard"},
+			[]string{"xsel", "--clipboard", "--input"})
+	}
+	for _, c := range candidates {
+		if _, err := lookPath(c[0]); err == nil {
+			return c[0], c[1:], true
+		}
+	}
+	return "", nil, false
+}
+
+// osc52 returns the escape sequence to set the clipboard of the terminal.
+// In tmux, the sequence is wrapped to pass through to the outer terminal.
+func osc52(s string, tmux bool) string {
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(s)) + "\a"
+	if tmux {
+		return "\x1bPtmux;\x1b" + seq + "\x1b\\"
+	}
+	return seq
+}
+
+// hasDisplay reports whether a browser can be shown to the user.
+func hasDisplay(goos string, getenv func(string) string) bool {
+	if isSSHSession(getenv) {
+		return false
+	}
+	switch goos {
+	case "darwin", "windows":
+		return true
+	}
+	if detectWSL() {
+		return true
+	}
+	return getenv("DISPLAY") != "" || getenv("WAYLAND_DISPLAY") != ""
+}
+
+func isSSHSession(getenv func(string) string) bool {
+	return getenv("SSH_CONNECTION") != "" || getenv("SSH_TTY") != ""
+}
+
+// openTTY opens the controlling terminal, falling back to stderr.
+func openTTY() io.Writer {
+	if f, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0); err == nil {
+		return f
+	}
+	return os.Stderr
+}
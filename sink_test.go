@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPrintSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := PrintSink(&buf)
+	if err := sink("https://foo.backlog.com/git/BAR/baz"); err != nil {
+		t.Fatalf("PrintSink() error = %v", err)
+	}
+	want := "https://foo.backlog.com/git/BAR/baz\n"
+	if got := buf.String(); got != want {
+		t.Errorf("PrintSink() wrote %q, want %q", got, want)
+	}
+}
+
+func Test_osc52(t *testing.T) {
+	type args struct {
+		s    string
+		tmux bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			args: args{"https://foo.backlog.com", false},
+			want: "\x1b]52;c;aHR0cHM6Ly9mb28uYmFja2xvZy5jb20=\a",
+		},
+		{
+			args: args{"https://foo.backlog.com", true},
+			want: "\x1bPtmux;\x1b\x1b]52;c;aHR0cHM6Ly9mb28uYmFja2xvZy5jb20=\a\x1b\\",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := osc52(tt.args.s, tt.args.tmux); got != tt.want {
+				t.Errorf("osc52() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_hasDisplay(t *testing.T) {
+	type args struct {
+		goos string
+		env  map[string]string
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{
+			args: args{"darwin", nil},
+			want: true,
+		},
+		{
+			args: args{"darwin", map[string]string{"SSH_CONNECTION": "10.0.0.1 22 10.0.0.2 22"}},
+			want: false,
+		},
+		{
+			args: args{"linux", map[string]string{"DISPLAY": ":0"}},
+			want: true,
+		},
+		{
+			args: args{"linux", map[string]string{"WAYLAND_DISPLAY": "wayland-0"}},
+			want: true,
+		},
+		{
+			args: args{"linux", nil},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string {
+				return tt.args.env[key]
+			}
+			if got := hasDisplay(tt.args.goos, getenv); got != tt.want {
+				t.Errorf("hasDisplay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_clipboardCommand(t *testing.T) {
+	type args struct {
+		goos      string
+		env       map[string]string
+		installed []string
+	}
+	tests := []struct {
+		name     string
+		args     args
+		wantName string
+		wantArgs []string
+		wantOk   bool
+	}{
+		{
+			args:     args{"darwin", nil, []string{"pbcopy"}},
+			wantName: "pbcopy",
+			wantArgs: []string{},
+			wantOk:   true,
+		},
+		{
+			args:     args{"linux", map[string]string{"WAYLAND_DISPLAY": "wayland-0", "DISPLAY": ":0"}, []string{"wl-copy", "xclip"}},
+			wantName: "wl-copy",
+			wantArgs: []string{},
+			wantOk:   true,
+		},
+		{
+			args:     args{"linux", map[string]string{"DISPLAY": ":0"}, []string{"xsel"}},
+			wantName: "xsel",
+			wantArgs: []string{"--clipboard", "--input"},
+			wantOk:   true,
+		},
+		{
+			args:   args{"linux", map[string]string{"DISPLAY": ":0", "SSH_TTY": "/dev/pts/0"}, []string{"xclip"}},
+			wantOk: false,
+		},
+		{
+			args:   args{"linux", nil, []string{"xclip"}},
+			wantOk: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string {
+				return tt.args.env[key]
+			}
+			lookPath := func(file string) (string, error) {
+				for _, v := range tt.args.installed {
+					if v == file {
+						return "/usr/bin/" + file, nil
+					}
+				}
+				return "", errors.New("not found")
+			}
+			gotName, gotArgs, gotOk := clipboardCommand(tt.args.goos, getenv, lookPath)
+			if gotOk != tt.wantOk {
+				t.Fatalf("clipboardCommand() gotOk = %v, want %v", gotOk, tt.wantOk)
+			}
+			if !gotOk {
+				return
+			}
+			if gotName != tt.wantName {
+				t.Errorf("clipboardCommand() gotName = %v, want %v", gotName, tt.wantName)
+			}
+			if !reflect.DeepEqual(gotArgs, tt.wantArgs) {
+				t.Errorf("clipboardCommand() gotArgs = %v, want %v", gotArgs, tt.wantArgs)
+			}
+		})
+	}
+}
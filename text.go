@@ -0,0 +1,173 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// textFormatBacklog is the text formatting rule of Backlog projects which use
+// the Backlog notation instead of Markdown for descriptions and comments.
+const textFormatBacklog = "backlog"
+
+const (
+	sgrBold   = 1
+	sgrItalic = 3
+	sgrStrike = 9
+)
+
+// renderText converts text written in the formatting rule of Backlog for the
+// terminal. The Backlog notation is converted into plain text like Markdown,
+// and Markdown is left as it is, except for the styles applied with color.
+func renderText(text, rule string, color bool) string {
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	if rule == textFormatBacklog {
+		return renderBacklogNotation(text, color)
+	}
+	return renderMarkdown(text, color)
+}
+
+var (
+	mdHeadingPattern = regexp.MustCompile(`^#{1,6}\s+`)
+	mdFencePattern   = regexp.MustCompile("^\\s*(```|~~~)")
+	mdInlinePatterns = []inlinePattern{
+		{regexp.MustCompile("`([^`]+)`"), func(m []string, color bool) string {
+			return colorize(m[1], colorCyan, color)
+		}},
+		{regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`), func(m []string, color bool) string {
+			return hyperlink(m[1], m[2])
+		}},
+		{regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`), func(m []string, color bool) string {
+			return colorize(m[1]+m[2], sgrBold, color)
+		}},
+		{regexp.MustCompile(`~~([^~]+)~~`), func(m []string, color bool) string {
+			return colorize(m[1], sgrStrike, color)
+		}},
+	}
+)
+
+func renderMarkdown(text string, color bool) string {
+	if !color {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	inCode := false
+	for i, line := range lines {
+		switch {
+		case mdFencePattern.MatchString(line):
+			inCode = !inCode
+			lines[i] = colorize(line, colorGray, true)
+		case inCode:
+			lines[i] = colorize(line, colorGray, true)
+		case mdHeadingPattern.MatchString(line):
+			lines[i] = colorize(line, sgrBold, true)
+		case strings.HasPrefix(line, ">"):
+			lines[i] = colorize(line, colorGray, true)
+		default:
+			lines[i] = replaceInline(line, mdInlinePatterns, true)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+var (
+	blHeadingPattern = regexp.MustCompile(`^(\*{1,6})\s*(.*)$`)
+	blListPattern    = regexp.MustCompile(`^(-+|\++)\s*(.*)$`)
+	blCodePattern    = regexp.MustCompile(`^\s*\{code(:[^}]*)?\}\s*$`)
+	blInlinePatterns = []inlinePattern{
+		{regexp.MustCompile(`\[\[([^\]]*?)[>:]((?:https?|ftp)://[^\]]+)\]\]`), func(m []string, color bool) string {
+			if !color {
+				return m[1] + " (" + m[2] + ")"
+			}
+			return hyperlink(m[1], m[2])
+		}},
+		{regexp.MustCompile(`'''(.+?)'''`), func(m []string, color bool) string {
+			return colorize(m[1], sgrItalic, color)
+		}},
+		{regexp.MustCompile(`''(.+?)''`), func(m []string, color bool) string {
+			return colorize(m[1], sgrBold, color)
+		}},
+		{regexp.MustCompile(`%%(.+?)%%`), func(m []string, color bool) string {
+			return colorize(m[1], sgrStrike, color)
+		}},
+		{regexp.MustCompile(`&color\([^)]*\)\s*\{(.*?)\}`), func(m []string, color bool) string {
+			return m[1]
+		}},
+	}
+)
+
+// renderBacklogNotation converts the Backlog notation, e.g. "* Heading",
+// bold text in two single quotes and "{code}", into plain text like Markdown.
+func renderBacklogNotation(text string, color bool) string {
+	var out []string
+	inCode, inQuote := false, false
+	// numbers are the counters of the numbered lists of each depth.
+	var numbers []int
+	for _, line := range strings.Split(text, "\n") {
+		if blCodePattern.MatchString(line) || strings.TrimSpace(line) == "{/code}" {
+			inCode = !inCode && strings.TrimSpace(line) != "{/code}"
+			continue
+		}
+		if inCode {
+			out = append(out, colorize("    "+line, colorGray, color))
+			continue
+		}
+		switch strings.TrimSpace(line) {
+		case "{quote}":
+			inQuote = true
+			continue
+		case "{/quote}":
+			inQuote = false
+			continue
+		}
+		m := blListPattern.FindStringSubmatch(line)
+		if m == nil {
+			numbers = nil
+		}
+		switch {
+		case inQuote || strings.HasPrefix(line, ">"):
+			line = strings.TrimPrefix(strings.TrimPrefix(line, ">"), " ")
+			line = colorize("> "+replaceInline(line, blInlinePatterns, color), colorGray, color)
+		case blHeadingPattern.MatchString(line):
+			h := blHeadingPattern.FindStringSubmatch(line)
+			line = strings.Repeat("#", len(h[1])) + " " + replaceInline(h[2], blInlinePatterns, false)
+			line = colorize(line, sgrBold, color)
+		case m != nil:
+			depth := len(m[1])
+			marker := "-"
+			if m[1][0] == '+' {
+				for len(numbers) < depth {
+					numbers = append(numbers, 0)
+				}
+				numbers = numbers[:depth]
+				numbers[depth-1]++
+				marker = strconv.Itoa(numbers[depth-1]) + "."
+			}
+			line = strings.Repeat("  ", depth-1) + marker + " " + replaceInline(m[2], blInlinePatterns, color)
+		default:
+			line = replaceInline(line, blInlinePatterns, color)
+		}
+		out = append(out, strings.Replace(line, "&br;", "\n", -1))
+	}
+	return strings.Join(out, "\n")
+}
+
+// inlinePattern replaces the matches of an inline notation in a line.
+type inlinePattern struct {
+	re      *regexp.Regexp
+	replace func(m []string, color bool) string
+}
+
+func replaceInline(line string, patterns []inlinePattern, color bool) string {
+	for _, p := range patterns {
+		line = p.re.ReplaceAllStringFunc(line, func(s string) string {
+			return p.replace(p.re.FindStringSubmatch(s), color)
+		})
+	}
+	return line
+}
+
+// indent prefixes each line of s with prefix.
+func indent(s, prefix string) string {
+	return prefix + strings.Replace(s, "\n", "\n"+prefix, -1)
+}
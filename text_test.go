@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func Test_renderText(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		rule  string
+		color bool
+		want  string
+	}{
+		{
+			name: "markdown",
+			text: "# Steps\r\n1. **Open** the [page](https://example.com)",
+			rule: "markdown",
+			want: "# Steps\n1. **Open** the [page](https://example.com)",
+		},
+		{
+			name:  "markdown with color",
+			text:  "# Steps\n**Open** `gitb`\n```\n**code**\n```",
+			rule:  "markdown",
+			color: true,
+			want:  "\x1b[1m# Steps\x1b[0m\n\x1b[1mOpen\x1b[0m \x1b[36mgitb\x1b[0m\n\x1b[90m```\x1b[0m\n\x1b[90m**code**\x1b[0m\n\x1b[90m```\x1b[0m",
+		},
+		{
+			name: "backlog notation",
+			text: "** Steps\n+Open\n++Click\n++Wait\n+''Log in''&br;again\n-%%Safari%%\n[[Docs>https://example.com]] &color(red){now}\n{quote}\nIt fails.\n{/quote}\n{code:go}\n-x\n{/code}",
+			rule: "backlog",
+			want: "## Steps\n1. Open\n  1. Click\n  2. Wait\n2. Log in\nagain\n- Safari\nDocs (https://example.com) now\n> It fails.\n    -x",
+		},
+		{
+			name:  "backlog notation with color",
+			text:  "''bold'' '''italic''' [[Docs:https://example.com]]",
+			rule:  "backlog",
+			color: true,
+			want:  "\x1b[1mbold\x1b[0m \x1b[3mitalic\x1b[0m \x1b]8;;https://example.com\x1b\\Docs\x1b]8;;\x1b\\",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderText(tt.text, tt.rule, tt.color); got != tt.want {
+				t.Errorf("renderText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}